@@ -1,15 +1,17 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"net/netip"
 	"os"
-	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -18,24 +20,50 @@ import (
 	"github.com/o0n1x/mass-translate-package/format"
 	"github.com/o0n1x/mass-translate-package/lang"
 	"github.com/o0n1x/mass-translate-package/provider"
-	"github.com/o0n1x/mass-translate-package/provider/deepl"
-	"github.com/o0n1x/mass-translate-package/translator"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
 	"github.com/o0n1x/mass-translate-server/internal/auth"
+	"github.com/o0n1x/mass-translate-server/internal/breaker"
 	"github.com/o0n1x/mass-translate-server/internal/cache"
 	"github.com/o0n1x/mass-translate-server/internal/database"
+	"github.com/o0n1x/mass-translate-server/internal/glossary"
+	"github.com/o0n1x/mass-translate-server/internal/lockout"
+	"github.com/o0n1x/mass-translate-server/internal/metrics"
+	"github.com/o0n1x/mass-translate-server/internal/ratelimit"
 	"github.com/redis/go-redis/v9"
 )
 
 // constants
-const MAXFILESIZE = 50 << 20
 const MAXQUERYSIZE = 100
 
+// the most texts sent to a provider in one request, DeepL takes up to 50
+const maxBatchTexts = 50
+
 type ApiConfig struct {
-	DB               *database.Queries
-	Redis            *redis.Client
-	Platform         string
-	DeeplClient      *deepl.DeepLClient
-	DeeplClientAPI   string
+	DB              *database.Queries
+	DBConn          *sql.DB
+	Redis           *redis.Client
+	Platform        string
+	Providers       map[provider.Provider]provider.Client
+	Breakers        map[provider.Provider]*breaker.Breaker
+	Fallback        []provider.Provider
+	GlossaryClients map[provider.Provider]glossary.Translator
+	// file extensions each provider accepts
+	AllowedExtensions map[provider.Provider]map[string]bool
+	MaxFileSize       int64
+	TokenTTL          time.Duration
+	RefreshTokenTTL   time.Duration
+	Lockout           *lockout.Lockout
+	RateLimiter       *ratelimit.Limiter
+	// requests per minute for users without a limit of their own or from their roles,
+	// and for each client IP across all requests, zero turns the IP limit off
+	RateLimit   int64
+	IPRateLimit int64
+	// proxies whose X-Forwarded-For is believed, see clientIP
+	TrustedProxies []netip.Prefix
+	// fuzzy matches at least this similar are suggested, and whether machine translations
+	// are recorded in the translation memory
+	TMThreshold      float32
+	TMRecord         bool
 	AdminCredentials struct {
 		Email    string
 		Password string
@@ -48,7 +76,7 @@ type User struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Email     string    `json:"email"`
-	IsAdmin   bool      `json:"is_admin"`
+	Roles     []string  `json:"roles"`
 }
 
 // handles all API functions
@@ -70,48 +98,96 @@ func (cfg *ApiConfig) Login(w http.ResponseWriter, r *http.Request) {
 	err := decoder.Decode(&params)
 	if err != nil {
 		log.Printf("Error decoding parameters: %s", err)
-		errorRespond(w, 400, "Invalid JSON in the request body")
+		errorRespond(w, apperr.ErrInvalidJSON)
+		return
+	}
+
+	// the attempt is counted before the password is checked so a locked account can not be
+	// probed and parallel guesses are all counted. if redis is down logins still work, just
+	// without the limits
+	ip := cfg.clientIP(r)
+	wait, locked, err := cfg.Lockout.Attempt(r.Context(), params.Email, ip)
+	if err != nil {
+		log.Printf("Error counting login attempt: %v", err)
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
 		return
 	}
 
 	user, err := cfg.DB.GetUserByEmail(r.Context(), params.Email)
 	if err != nil {
 		log.Printf("user not found: %v", err)
-		errorRespond(w, 401, "Incorrect email or password")
+		cfg.loginFailed(w, r, params.Email, locked)
 		return
 	}
 
 	ok, err := auth.CheckPasswordHash(params.Password, user.HashedPassword.String)
 	if !ok {
 		log.Printf("password does not match: %v", err)
-		errorRespond(w, 401, "Incorrect email or password")
+		cfg.loginFailed(w, r, params.Email, locked)
 		return
 	}
 
-	jwt_token, err := auth.MakeJWT(user.ID, cfg.SECRET_JWT, time.Hour) //TODO: remove hardcoded time limit
+	err = cfg.Lockout.Success(r.Context(), params.Email, ip)
+	if err != nil {
+		log.Printf("Error resetting login failures: %v", err)
+	}
+
+	session, err := cfg.createSession(r.Context(), user)
 	if err != nil {
-		log.Printf("Error creating token: %v", err)
-		errorRespond(w, 500, "Failed to create token")
+		log.Printf("Error creating session: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to create token"))
 		return
 	}
 
-	jsonRespond(w, 200, struct {
-		ID    uuid.UUID `json:"id"`
-		Email string    `json:"email"`
-		Token string    `json:"token"`
-	}{
-		ID:    user.ID,
-		Email: user.Email,
-		Token: jwt_token,
-	})
+	metrics.LoginSucceeded()
+	jsonRespond(w, 200, session)
+
+}
+
+// unknown emails are answered like wrong passwords so the responses are the same either way.
+// the failure was already counted by the attempt, locked is whether that locked the email
+func (cfg *ApiConfig) loginFailed(w http.ResponseWriter, r *http.Request, email string, locked bool) {
+	metrics.LoginFailed()
+	if locked {
+		log.Printf("logins for %s locked for %v", email, cfg.Lockout.Duration())
+		cfg.audit(r, AuditLoginLocked, nil, email, fmt.Sprintf("locked for %v after too many failed logins", cfg.Lockout.Duration()))
+	}
+	errorRespond(w, apperr.ErrInvalidCredentials)
+}
+
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second).Seconds())))
+	errorRespond(w, apperr.ErrTooManyAttempts)
+}
+
+// lifts a login lock early, e.g. after the user confirmed it was them
+func (cfg *ApiConfig) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.userFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.Lockout.Unlock(r.Context(), user.Email)
+	if err != nil {
+		log.Printf("Error unlocking user %v: %v", user.ID, err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error unlocking user"))
+		return
+	}
+
+	admin := r.Context().Value("user").(database.User)
+	cfg.audit(r, AuditLoginUnlocked, &admin.ID, user.Email, "")
 
+	w.WriteHeader(204)
 }
 
 func (cfg *ApiConfig) Register(w http.ResponseWriter, r *http.Request) {
 
 	type parameters struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+		Password string   `json:"password"`
+		Email    string   `json:"email"`
+		Roles    []string `json:"roles"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -119,28 +195,54 @@ func (cfg *ApiConfig) Register(w http.ResponseWriter, r *http.Request) {
 	err := decoder.Decode(&params)
 	if err != nil {
 		log.Printf("Error decoding parameters: %s", err)
-		errorRespond(w, 400, "Invalid JSON in the request body")
+		errorRespond(w, apperr.ErrInvalidJSON)
 		return
 	}
 
-	hashedpass, err := auth.HashPassword(params.Password)
+	// new users only translate unless someone allowed to assign roles says otherwise
+	if params.Roles == nil {
+		params.Roles = []string{auth.RoleTranslator}
+	} else {
+		if !hasPermission(r, auth.PermRolesAssign) {
+			errorRespond(w, apperr.ErrForbidden.WithMessage("assigning roles needs the roles.assign permission"))
+			return
+		}
+		err = cfg.checkAssignableRoles(r, params.Roles)
+		if err != nil {
+			errorRespond(w, err)
+			return
+		}
+	}
+
+	_, err = cfg.createUser(r.Context(), cfg.DB, params.Email, params.Password, params.Roles)
 	if err != nil {
-		log.Printf("Error creating user: %v", err)
+		log.Printf("User Registeration Failed: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("User Registeration Failed"))
 		return
 	}
 
-	// is_admin is set false by default for security, and then updated manually via SQL
-	_, err = cfg.DB.CreateUser(context.Background(), database.CreateUserParams{
-		Email:          params.Email,
+}
+
+// db is cfg.DB or a transaction the user is created in
+func (cfg *ApiConfig) createUser(ctx context.Context, db *database.Queries, email string, password string, roles []string) (database.User, error) {
+	hashedpass, err := auth.HashPassword(password)
+	if err != nil {
+		return database.User{}, fmt.Errorf("hashing password: %w", err)
+	}
+
+	user, err := db.CreateUser(ctx, database.CreateUserParams{
+		Email:          email,
 		HashedPassword: sql.NullString{String: hashedpass, Valid: true},
-		IsAdmin:        false,
 	})
 	if err != nil {
-		log.Printf("User Registeration Failed: %v", err)
-		errorRespond(w, 500, "User Registeration Failed")
-		return
+		return database.User{}, err
 	}
 
+	err = db.SetUserRoles(ctx, database.SetUserRolesParams{UserID: user.ID, Roles: roles})
+	if err != nil {
+		return database.User{}, fmt.Errorf("setting roles of user %v: %w", user.ID, err)
+	}
+	return user, nil
 }
 
 func (cfg *ApiConfig) RegisterAdmin() {
@@ -160,10 +262,18 @@ func (cfg *ApiConfig) RegisterAdmin() {
 		return
 	}
 
-	_, err = cfg.DB.CreateUser(context.Background(), database.CreateUserParams{
+	admin, err := cfg.DB.CreateUser(context.Background(), database.CreateUserParams{
 		Email:          cfg.AdminCredentials.Email,
 		HashedPassword: sql.NullString{String: hashedpass, Valid: true},
-		IsAdmin:        true,
+	})
+	if err != nil {
+		log.Printf("Initial Admin Registeration Failed: %v", err)
+		os.Exit(1)
+		return
+	}
+	err = cfg.DB.SetUserRoles(context.Background(), database.SetUserRolesParams{
+		UserID: admin.ID,
+		Roles:  []string{auth.RoleSuperAdmin},
 	})
 	if err != nil {
 		log.Printf("Initial Admin Registeration Failed: %v", err)
@@ -180,62 +290,41 @@ func (cfg *ApiConfig) GetUsers(w http.ResponseWriter, r *http.Request) {
 		userUUID, err := uuid.Parse(userID)
 		if err != nil {
 			log.Printf("Error invalid user ID: %v", err)
-			errorRespond(w, 400, "invalid ID")
+			errorRespond(w, apperr.ErrInvalidID)
 			return
 		}
 
 		user, err := cfg.DB.GetUser(r.Context(), userUUID)
 		if err != nil {
 			log.Printf("Error retrieving user: %v", err)
-			errorRespond(w, 404, "user not found")
+			errorRespond(w, apperr.ErrUserNotFound)
 			return
 		}
 
-		jsonRespond(w, 200, User{
-			ID:        user.ID,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-			Email:     user.Email,
-			IsAdmin:   user.IsAdmin,
-		})
+		returnedUsers, err := cfg.usersResponse(r.Context(), []database.User{user})
+		if err != nil {
+			log.Printf("Error retrieving user roles: %v", err)
+			errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve user"))
+			return
+		}
+		jsonRespond(w, 200, returnedUsers[0])
 		return
 	}
 
-	limit := 10
-	offset := 0
-	limit_query := r.URL.Query().Get("limit")
-	offset_query := r.URL.Query().Get("offset")
-
-	if limit_query != "" {
-		parsed, err := strconv.Atoi(limit_query)
-		if err == nil && parsed > 0 && parsed <= MAXQUERYSIZE {
-			limit = parsed
-		}
-	}
-	if offset_query != "" {
-		parsed, err := strconv.Atoi(offset_query)
-		if err == nil && parsed >= 0 {
-			offset = parsed
-		}
-	}
+	limit, offset := getPagination(r)
 
 	users, err := cfg.DB.GetUsers(r.Context(), database.GetUsersParams{Limit: int32(limit), Offset: int32(offset)})
 	if err != nil {
 		log.Printf("Error retrieving users: %v", err)
-		errorRespond(w, 500, "Failed to retrieve users")
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve users"))
 		return
 	}
 
-	returnedUsers := []User{}
-
-	for _, user := range users {
-		returnedUsers = append(returnedUsers, User{
-			ID:        user.ID,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-			Email:     user.Email,
-			IsAdmin:   user.IsAdmin,
-		})
+	returnedUsers, err := cfg.usersResponse(r.Context(), users)
+	if err != nil {
+		log.Printf("Error retrieving user roles: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve users"))
+		return
 	}
 
 	jsonRespond(w, 200, returnedUsers)
@@ -248,21 +337,28 @@ func (cfg *ApiConfig) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	userUUID, err := uuid.Parse(userID)
 	if err != nil {
 		log.Printf("Error invalid user ID: %v", err)
-		errorRespond(w, 400, "invalid ID")
+		errorRespond(w, apperr.ErrInvalidID)
 		return
 	}
 
 	user, err := cfg.DB.GetUser(r.Context(), userUUID)
 	if err != nil {
 		log.Printf("Error retrieving user: %v", err)
-		errorRespond(w, 404, "user not found")
+		errorRespond(w, apperr.ErrUserNotFound)
 		return
 	}
 
+	err = cfg.checkCanManageUser(r, user.ID)
+	if err != nil {
+		errorRespond(w, err)
+		return
+	}
+
+	// roles replace the current roles when given
 	type parameters struct {
-		Email    *string `json:"email,omitempty"`
-		IsAdmin  *bool   `json:"is_admin,omitempty"`
-		Password *string `json:"password,omitempty"`
+		Email    *string   `json:"email,omitempty"`
+		Password *string   `json:"password,omitempty"`
+		Roles    *[]string `json:"roles,omitempty"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -270,24 +366,33 @@ func (cfg *ApiConfig) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	err = decoder.Decode(&params)
 	if err != nil {
 		log.Printf("Error decoding parameters: %s", err)
-		errorRespond(w, 400, "Invalid JSON in the request body")
+		errorRespond(w, apperr.ErrInvalidJSON)
 		return
 	}
 
 	if params.Email == nil {
 		params.Email = &user.Email
 	}
-	if params.IsAdmin == nil {
-		params.IsAdmin = &user.IsAdmin
+	if params.Roles != nil {
+		if !hasPermission(r, auth.PermRolesAssign) {
+			errorRespond(w, apperr.ErrForbidden.WithMessage("assigning roles needs the roles.assign permission"))
+			return
+		}
+		err = cfg.checkAssignableRoles(r, *params.Roles)
+		if err != nil {
+			errorRespond(w, err)
+			return
+		}
 	}
 
+	passwordChanged := params.Password != nil
 	if params.Password == nil {
 		params.Password = &user.HashedPassword.String
 	} else {
 		hashedpass, err := auth.HashPassword(*params.Password)
 		if err != nil {
 			log.Printf("Error updating user: %v", err)
-			errorRespond(w, 500, "error updating user")
+			errorRespond(w, apperr.ErrInternal.WithMessage("error updating user"))
 			return
 		}
 		params.Password = &hashedpass
@@ -296,22 +401,40 @@ func (cfg *ApiConfig) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	newuser, err := cfg.DB.UpdateUser(r.Context(), database.UpdateUserParams{
 		ID:             userUUID,
 		Email:          *params.Email,
-		IsAdmin:        *params.IsAdmin,
 		HashedPassword: sql.NullString{String: *params.Password, Valid: true},
 	})
 	if err != nil {
 		log.Printf("Error updating user: %v", err)
-		errorRespond(w, 500, "error updating user")
+		errorRespond(w, apperr.ErrInternal.WithMessage("error updating user"))
 		return
 	}
 
-	jsonRespond(w, 200, User{
-		ID:        newuser.ID,
-		UpdatedAt: newuser.UpdatedAt,
-		CreatedAt: newuser.CreatedAt,
-		Email:     newuser.Email,
-		IsAdmin:   newuser.IsAdmin,
-	})
+	if params.Roles != nil {
+		err = cfg.DB.SetUserRoles(r.Context(), database.SetUserRolesParams{UserID: userUUID, Roles: *params.Roles})
+		if err != nil {
+			log.Printf("Error setting roles of user %v: %v", userUUID, err)
+			errorRespond(w, apperr.ErrInternal.WithMessage("error updating user"))
+			return
+		}
+	}
+
+	// a new password logs the user out of every session, old tokens may be compromised
+	if passwordChanged {
+		err = cfg.revokeSessions(r.Context(), userUUID)
+		if err != nil {
+			log.Printf("Error revoking sessions of user %v: %v", userUUID, err)
+			errorRespond(w, apperr.ErrInternal.WithMessage("error updating user"))
+			return
+		}
+	}
+
+	returnedUsers, err := cfg.usersResponse(r.Context(), []database.User{newuser})
+	if err != nil {
+		log.Printf("Error retrieving user roles: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error updating user"))
+		return
+	}
+	jsonRespond(w, 200, returnedUsers[0])
 
 }
 
@@ -321,42 +444,49 @@ func (cfg *ApiConfig) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	userUUID, err := uuid.Parse(userID)
 	if err != nil {
 		log.Printf("Error invalid user ID: %v", err)
-		errorRespond(w, 400, "invalid ID")
+		errorRespond(w, apperr.ErrInvalidID)
 		return
 	}
 
 	_, err = cfg.DB.GetUser(r.Context(), userUUID)
 	if err != nil {
 		log.Printf("Error retrieving user: %v", err)
-		errorRespond(w, 404, "user not found")
+		errorRespond(w, apperr.ErrUserNotFound)
+		return
+	}
+
+	err = cfg.checkCanManageUser(r, userUUID)
+	if err != nil {
+		errorRespond(w, err)
 		return
 	}
 
+	// sessions are deleted with the user, so their tokens stop working right away
 	err = cfg.DB.DeleteUser(r.Context(), userUUID)
 	if err != nil {
 		log.Printf("Error deleting user: %v", err)
-		errorRespond(w, 500, "error deleting user")
+		errorRespond(w, apperr.ErrInternal.WithMessage("error deleting user"))
 		return
 	}
 
 	w.WriteHeader(204)
 }
 
-func (cfg *ApiConfig) DeeplTranslate(w http.ResponseWriter, r *http.Request) {
+func (cfg *ApiConfig) Translate(w http.ResponseWriter, r *http.Request) {
 
-	if cfg.DeeplClient == nil {
-		generalizedclient, _ := provider.GetClient(provider.DeepL, cfg.DeeplClientAPI)
-		cfg.DeeplClient = generalizedclient.(*deepl.DeepLClient)
+	client, ok := cfg.providerFromRequest(w, r)
+	if !ok {
+		return
 	}
 
 	contentType := r.Header.Get("Content-Type")
 
 	if strings.HasPrefix(contentType, "multipart/form-data") {
-		cfg.fileTranslateHelper(w, r)
+		cfg.fileTranslateHelper(w, r, client)
 	} else if contentType == "application/json" {
-		cfg.textTranslateHelper(w, r)
+		cfg.textTranslateHelper(w, r, client)
 	} else {
-		http.Error(w, "unsupported content type", http.StatusBadRequest)
+		errorRespond(w, apperr.ErrUnsupportedContentType)
 	}
 }
 
@@ -364,18 +494,48 @@ func (cfg *ApiConfig) DeeplTranslate(w http.ResponseWriter, r *http.Request) {
 //Helper Functions
 //
 
-func (cfg *ApiConfig) textTranslateHelper(w http.ResponseWriter, r *http.Request) {
+// reads limit and offset from the query, invalid or out of range values fall back to the defaults
+func getPagination(r *http.Request) (int, int) {
+	limit := 10
+	offset := 0
+	limit_query := r.URL.Query().Get("limit")
+	offset_query := r.URL.Query().Get("offset")
+
+	if limit_query != "" {
+		parsed, err := strconv.Atoi(limit_query)
+		if err == nil && parsed > 0 && parsed <= MAXQUERYSIZE {
+			limit = parsed
+		}
+	}
+	if offset_query != "" {
+		parsed, err := strconv.Atoi(offset_query)
+		if err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+	return limit, offset
+}
+
+func (cfg *ApiConfig) textTranslateHelper(w http.ResponseWriter, r *http.Request, client provider.Client) {
 	type parameters struct {
 		Text       []string `json:"text"`
 		SourceLang string   `json:"source_lang"`
 		TargetLang string   `json:"target_lang"`
+		GlossaryID string   `json:"glossary_id"`
+		// also return close translation memory matches for texts that were translated
+		Suggestions bool `json:"suggestions"`
 	}
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
 	err := decoder.Decode(&params)
 	if err != nil {
-		http.Error(w, "Invalid JSON in the request body", http.StatusBadRequest)
 		log.Printf("Error decoding parameters: %s", err)
+		errorRespond(w, apperr.ErrInvalidJSON)
+		return
+	}
+
+	if len(params.Text) == 0 {
+		errorRespond(w, apperr.ErrInvalidRequest.WithMessage("text is required"))
 		return
 	}
 
@@ -386,139 +546,296 @@ func (cfg *ApiConfig) textTranslateHelper(w http.ResponseWriter, r *http.Request
 		To:      lang.Language(params.TargetLang),
 	}
 
-	cached, hit, err := cache.GetCache(r.Context(), cfg.Redis, provider.DeepL, req)
+	user := r.Context().Value("user").(database.User)
+
+	g, err := cfg.glossaryForRequest(r, user, params.GlossaryID, &req)
 	if err != nil {
-		log.Printf("cache error: %v", err)
+		log.Printf("Error loading glossary: %v", err)
+		errorRespond(w, err)
+		return
 	}
-	if hit {
-		log.Print("Cache HIT")
-		w.Header().Set("X-Cache", "HIT")
-		textRespond(w, cached.Text)
+	res, err := cfg.translateSegments(r.Context(), user.ID, client, req, g)
+	w.Header().Set("X-Provider", string(res.served.Name()))
+	if err != nil {
+		log.Printf("Error translating: %v", err)
+		errorRespond(w, err)
 		return
 	}
+	res.setHeaders(w)
+
+	var suggestions [][]TMSuggestion
+	if params.Suggestions {
+		matches := cfg.tmSuggestions(r.Context(), res.missed)
+		suggestions = make([][]TMSuggestion, len(req.Text))
+		for i, segment := range req.Text {
+			suggestions[i] = []TMSuggestion{}
+			if match, ok := matches[segment]; ok {
+				suggestions[i] = match
+			}
+		}
+	}
 
-	res, err := cfg.DeeplClient.Translate(r.Context(), req)
+	textRespond(w, res.translations, suggestions)
+
+}
+
+// the outcome of translating a batch of texts
+type segmentResult struct {
+	translations []string
+	// which texts came from the cache or the translation memory
+	hits   []bool
+	tmHits int
+	// the texts that went to the provider, each once, and the provider that translated them
+	missed provider.Request
+	served provider.Client
+}
+
+// translates each text on its own through the segment cache and the translation memory.
+// only the texts neither of them has go to the provider, each distinct text once, and count
+// toward the quota. every text is recorded in the usage log. served is set on errors too
+func (cfg *ApiConfig) translateSegments(ctx context.Context, userID uuid.UUID, client provider.Client, req provider.Request, g *glossary.Glossary) (segmentResult, error) {
+	glossaryVersion := ""
+	if g != nil {
+		glossaryVersion = g.Version()
+	}
+
+	translations, hits, err := cache.GetSegments(ctx, cfg.Redis, cache.NewKey(client, req).WithGlossary(glossaryVersion), req.Text)
 	if err != nil {
-		if strings.Contains(err.Error(), "Invalid Source Language") {
-			http.Error(w, "Error translating: Invalid Source Language", http.StatusBadRequest)
-		} else if strings.Contains(err.Error(), "Invalid Target Language") {
-			http.Error(w, "Error translating: Invalid Target Language", http.StatusBadRequest)
-		} else {
-			http.Error(w, "Error translating", http.StatusInternalServerError)
+		log.Printf("cache error: %v", err)
+	}
+	// approved translation memory entries are served like cache hits. they are made for the
+	// plain language pair, so a glossary wins over them
+	tmHits := 0
+	if g == nil {
+		tmHits = cfg.matchTranslationMemory(ctx, req, translations, hits)
+	}
+
+	hitReq, missReq := req, req
+	hitReq.Text, missReq.Text = []string{}, []string{}
+	positions := map[string][]int{}
+	for i, segment := range req.Text {
+		if hits[i] {
+			hitReq.Text = append(hitReq.Text, segment)
+			continue
+		}
+		if _, seen := positions[segment]; !seen {
+			missReq.Text = append(missReq.Text, segment)
 		}
+		positions[segment] = append(positions[segment], i)
+	}
+	result := segmentResult{translations: translations, hits: hits, tmHits: tmHits, missed: missReq, served: client}
 
-		log.Printf("Error translating: %v", err)
-		return
+	if len(missReq.Text) == 0 {
+		log.Print("Cache HIT")
+		cfg.recordTranslation(ctx, userID, client.Name(), req, true, nil)
+		return result, nil
 	}
 
-	err = cache.SetCache(r.Context(), cfg.Redis, provider.DeepL, req, res)
+	chars, bytes := requestUsage(missReq)
+	err = cfg.checkQuota(ctx, userID, chars, bytes)
 	if err != nil {
-		log.Printf("cache set error: %v", err)
+		log.Printf("Quota check for user %v: %v", userID, err)
+		if errors.Is(err, apperr.ErrQuotaExceeded) {
+			cfg.recordTranslation(ctx, userID, client.Name(), missReq, false, err)
+		}
+		return result, err
 	}
 
-	w.Header().Set("X-Cache", "MISS")
-	textRespond(w, res.Text)
+	// providers cap the texts of one request, a localization file can have thousands.
+	// batches already translated stay cached when a later one fails
+	for start := 0; start < len(missReq.Text); start += maxBatchTexts {
+		batch := missReq
+		batch.Text = missReq.Text[start:min(start+maxBatchTexts, len(missReq.Text))]
+
+		res, served, err := cfg.translateWithFallback(ctx, client, batch, g)
+		if err == nil && len(res.Text) != len(batch.Text) {
+			err = apperr.ErrTranslationFailed.WithMessagef("provider returned %d translations for %d texts", len(res.Text), len(batch.Text))
+		}
+		cfg.recordTranslation(ctx, userID, served.Name(), batch, false, err)
+		result.served = served
+		if err != nil {
+			return result, err
+		}
+
+		for i, segment := range batch.Text {
+			for _, position := range positions[segment] {
+				translations[position] = res.Text[i]
+			}
+		}
 
+		err = cache.SetSegments(ctx, cfg.Redis, cache.NewKey(served, batch).WithGlossary(glossaryVersion), batch.Text, res.Text)
+		if err != nil {
+			log.Printf("cache set error: %v", err)
+		}
+		// translations made with a glossary follow it rather than the plain language pair
+		if g == nil {
+			cfg.recordTranslationMemory(ctx, served.Name(), batch, res.Text)
+		}
+	}
+	if len(hitReq.Text) > 0 {
+		cfg.recordTranslation(ctx, userID, client.Name(), hitReq, true, nil)
+	}
+	return result, nil
 }
-func textRespond(w http.ResponseWriter, text []string) {
+
+// X-Cache is HIT, MISS or PARTIAL, X-Cache-Hits and X-TM-Hits count the texts that did not
+// go to the provider
+func (res segmentResult) setHeaders(w http.ResponseWriter) {
+	hits := 0
+	for _, hit := range res.hits {
+		if hit {
+			hits++
+		}
+	}
+	w.Header().Set("X-Cache-Hits", fmt.Sprintf("%d/%d", hits, len(res.hits)))
+	w.Header().Set("X-TM-Hits", fmt.Sprintf("%d/%d", res.tmHits, len(res.hits)))
+	switch {
+	case len(res.missed.Text) == 0:
+		w.Header().Set("X-Cache", "HIT")
+	case hits > 0:
+		w.Header().Set("X-Cache", "PARTIAL")
+	default:
+		w.Header().Set("X-Cache", "MISS")
+	}
+}
+
+// suggestions are left out when nil
+func textRespond(w http.ResponseWriter, text []string, suggestions [][]TMSuggestion) {
 	type TextResponse struct {
-		Translations []string `json:"translation"`
+		Translations []string         `json:"translation"`
+		Suggestions  [][]TMSuggestion `json:"suggestions,omitempty"`
 	}
-	textres := TextResponse{Translations: text}
+	textres := TextResponse{Translations: text, Suggestions: suggestions}
 	dat, err := json.Marshal(textres)
 	if err != nil {
-		http.Error(w, "Error marshalling JSON", http.StatusInternalServerError)
 		log.Printf("Error marshalling JSON: %s", err)
+		errorRespond(w, apperr.ErrInternal)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(dat)
 }
 
-// TODO: limit how large the cache can be. atm even a 1GB file will be cached
-func (cfg *ApiConfig) fileTranslateHelper(w http.ResponseWriter, r *http.Request) {
-	r.Body = http.MaxBytesReader(w, r.Body, MAXFILESIZE)
-
-	file, header, err := r.FormFile("file")
-	if err != nil {
-		http.Error(w, "file required", http.StatusBadRequest)
+func (cfg *ApiConfig) fileTranslateHelper(w http.ResponseWriter, r *http.Request, client provider.Client) {
+	file, fields, ok := cfg.receiveUpload(w, r, client)
+	if !ok {
 		return
 	}
 	defer file.Close()
 
-	if !isFileAllowedDeepl(header.Filename) {
-		http.Error(w, "invalid file type", http.StatusBadRequest)
+	if fields["target_lang"] == "" {
+		errorRespond(w, apperr.ErrInvalidTargetLang.WithMessage("invalid form no target language"))
 		return
 	}
 
-	if r.FormValue("target_lang") == "" {
-		http.Error(w, "invalid form no target language", http.StatusBadRequest)
-		return
+	req := provider.Request{
+		ReqType:  format.File,
+		FileName: file.name,
+		From:     lang.Language(fields["source_lang"]),
+		To:       lang.Language(fields["target_lang"]),
 	}
 
-	data, err := io.ReadAll(file)
+	user := r.Context().Value("user").(database.User)
+
+	g, err := cfg.glossaryForRequest(r, user, fields["glossary_id"], &req)
 	if err != nil {
-		http.Error(w, "failed to read file", http.StatusInternalServerError)
-		log.Printf("Error reading file: %v", err)
+		log.Printf("Error loading glossary: %v", err)
+		errorRespond(w, err)
 		return
 	}
 
-	req := provider.Request{
-		ReqType:  format.File,
-		Binary:   data,
-		FileName: header.Filename,
-		From:     lang.Language(r.FormValue("source_lang")),
-		To:       lang.Language(r.FormValue("target_lang")),
+	if translatedOnServer(req.FileName) {
+		opts, err := subtitleOptions(fields)
+		if err != nil {
+			errorRespond(w, err)
+			return
+		}
+		res, err := cfg.translateOnServer(r.Context(), user.ID, client, req, file, g, opts)
+		w.Header().Set("X-Provider", string(res.served.Name()))
+		if err != nil {
+			log.Printf("Error translating: %v", err)
+			errorRespond(w, err)
+			return
+		}
+		res.setHeaders(w)
+		fileRespond(w, r, bytes.NewReader(res.file), res.filename, time.Time{})
+		return
+	}
+
+	glossaryVersion := ""
+	if g != nil {
+		glossaryVersion = g.Version()
 	}
 
-	cached, hit, err := cache.GetCache(r.Context(), cfg.Redis, provider.DeepL, req)
+	cached, hit, err := cache.GetFile(r.Context(), cfg.Redis, cache.NewKey(client, req).WithGlossary(glossaryVersion), file.hash)
 	if err != nil {
 		log.Printf("cache error: %v", err)
 	}
 	if hit {
 		log.Print("Cache HIT")
+		cfg.recordTranslationUsage(r.Context(), user.ID, client.Name(), req, 0, file.size, true, nil)
 		w.Header().Set("X-Cache", "HIT")
-		fileRespond(w, cached.Binary, req.FileName)
+		w.Header().Set("X-Provider", string(client.Name()))
+		fileRespond(w, r, cached, req.FileName, time.Time{})
 		return
 	}
 
-	res, err := translator.Translate(r.Context(), req, cfg.DeeplClient)
+	req.Binary, err = file.bytes()
 	if err != nil {
-		if strings.Contains(err.Error(), "Invalid Source Language") {
-			http.Error(w, "Error translating: Invalid Source Language", http.StatusBadRequest)
-		} else if strings.Contains(err.Error(), "Invalid Target Language") {
-			http.Error(w, "Error translating: Invalid Target Language", http.StatusBadRequest)
-		} else {
-			http.Error(w, "Error translating", http.StatusInternalServerError)
+		log.Printf("Error reading file: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("failed to read file"))
+		return
+	}
+
+	chars, size := requestUsage(req)
+	err = cfg.checkQuota(r.Context(), user.ID, chars, size)
+	if err != nil {
+		log.Printf("Quota check for user %v: %v", user.ID, err)
+		if errors.Is(err, apperr.ErrQuotaExceeded) {
+			cfg.recordTranslation(r.Context(), user.ID, client.Name(), req, false, err)
 		}
+		errorRespond(w, err)
+		return
+	}
+
+	res, served, err := cfg.translateWithFallback(r.Context(), client, req, g)
+	cfg.recordTranslation(r.Context(), user.ID, served.Name(), req, false, err)
+	w.Header().Set("X-Provider", string(served.Name()))
+	if err != nil {
 		log.Printf("Error translating: %v", err)
+		errorRespond(w, err)
 		return
 	}
 
-	err = cache.SetCache(r.Context(), cfg.Redis, provider.DeepL, req, res)
+	err = cache.SetFile(r.Context(), cfg.Redis, cache.NewKey(served, req).WithGlossary(glossaryVersion), file.hash, res.Binary)
 	if err != nil {
 		log.Printf("cache set error: %v", err)
 	}
 
 	w.Header().Set("X-Cache", "MISS")
-	fileRespond(w, res.Binary, req.FileName)
+	fileRespond(w, r, bytes.NewReader(res.Binary), req.FileName, time.Time{})
 
 }
 
-func fileRespond(w http.ResponseWriter, binary []byte, filename string) {
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"translated_%s\"", filename))
-	w.Write(binary)
-}
+// responds with the code and message of the apperr.Error in err. anything else is
+// unexpected and served as an internal error without leaking its details
+func errorRespond(w http.ResponseWriter, err error) {
+	var appErr *apperr.Error
+	if !errors.As(err, &appErr) {
+		log.Printf("Unexpected error: %v", err)
+		appErr = apperr.ErrInternal
+	}
 
-func errorRespond(w http.ResponseWriter, code int, msg string) {
 	type returnErr struct {
-		Error string `json:"error"`
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
 	}
 
-	respBody := returnErr{
-		Error: msg,
-	}
+	respBody := returnErr{}
+	respBody.Error.Code = appErr.Code
+	respBody.Error.Message = appErr.Message
 	dat, err := json.Marshal(respBody)
 	if err != nil {
 		log.Printf("Error marshalling JSON: %s", err)
@@ -526,10 +843,19 @@ func errorRespond(w http.ResponseWriter, code int, msg string) {
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
+	w.WriteHeader(appErr.Status)
 	w.Write(dat)
 }
 
+// a body over the max file size shows up as a form error, tell it apart from a missing file
+func formFileError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return apperr.ErrFileTooLarge.WithMessagef("file too large, the limit is %d bytes", maxBytesErr.Limit)
+	}
+	return apperr.ErrFileRequired
+}
+
 func jsonRespond(w http.ResponseWriter, code int, payload interface{}) {
 	dat, err := json.Marshal(payload)
 	if err != nil {
@@ -542,70 +868,90 @@ func jsonRespond(w http.ResponseWriter, code int, payload interface{}) {
 	w.Write(dat)
 }
 
-func isFileAllowedDeepl(filename string) bool {
-	allowed := map[string]bool{
-		".srt":  true,
-		".txt":  true,
-		".docx": true}
-
-	ext := filepath.Ext(filename)
-
-	return allowed[ext]
-}
-
 //
 // Middleware
 //
 
+// lets in any signed in user, API keys need the user scope
 func (cfg *ApiConfig) MiddlewareIsUser(next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return cfg.middleware(auth.ScopeUser, "", next)
+}
+
+// lets in users with the permission. API keys also need the scope the permission belongs to
+func (cfg *ApiConfig) MiddlewarePermission(permission string, next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return cfg.middleware(auth.PermissionScope(permission), permission, next)
+}
+
+func (cfg *ApiConfig) middleware(scope string, permission string, next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		token, err := auth.GetBearerToken(r.Header)
-		if err != nil {
-			log.Printf("Error parsing header: %v", err)
-			errorRespond(w, 401, "Token missing or invalid, Please Login First")
-			return
-		}
-		userid, err := auth.ValidateJWT(token, cfg.SECRET_JWT)
+		creds, err := cfg.authenticate(r)
 		if err != nil {
-			log.Printf("Error validating token: %v", err)
-			errorRespond(w, 401, "Token missing or invalid, Please Login First")
+			log.Printf("Error authenticating: %v", err)
+			errorRespond(w, apperr.ErrUnauthorized)
 			return
 		}
-		user, err := cfg.DB.GetUser(r.Context(), userid)
-		if err != nil {
-			log.Printf("Error getting user: %v", err)
-			errorRespond(w, 401, "Token missing or invalid, Please Login First")
-			return
-		}
-		ctx := context.WithValue(r.Context(), "user", user)
-		next(w, r.WithContext(ctx))
-	}
-}
-func (cfg *ApiConfig) MiddlewareIsAdmin(next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		token, err := auth.GetBearerToken(r.Header)
-		if err != nil {
-			log.Printf("Error parsing header: %v", err)
-			errorRespond(w, 401, "Token missing or invalid")
+		if !cfg.allowUser(w, r, creds.user) {
 			return
 		}
-		userid, err := auth.ValidateJWT(token, cfg.SECRET_JWT)
-		if err != nil {
-			log.Printf("Error validating token: %v", err)
-			errorRespond(w, 401, "Token missing or invalid")
+		if !creds.allows(scope) {
+			log.Printf("API key of user %v lacks the %s scope", creds.user.ID, scope)
+			errorRespond(w, apperr.ErrForbidden.WithMessagef("API key does not have the %s scope", scope))
 			return
 		}
-		user, err := cfg.DB.GetUser(r.Context(), userid)
+		permissions, err := cfg.userPermissions(r.Context(), creds)
 		if err != nil {
-			log.Printf("Error getting user: %v", err)
-			errorRespond(w, 401, "Token missing or invalid")
+			log.Printf("Error getting permissions of user %v: %v", creds.user.ID, err)
+			errorRespond(w, apperr.ErrInternal)
 			return
 		}
-		if !user.IsAdmin {
-			log.Printf("user %v attempted an admin action", user.ID)
-			errorRespond(w, 403, "Forbidden")
+		if permission != "" && !slices.Contains(permissions, permission) {
+			log.Printf("user %v attempted an action needing %s", creds.user.ID, permission)
+			errorRespond(w, apperr.ErrForbidden.WithMessagef("missing the %s permission", permission))
 			return
 		}
-		next(w, r)
+		ctx := context.WithValue(r.Context(), "user", creds.user)
+		ctx = context.WithValue(ctx, "permissions", permissions)
+		// nil for sessions, they are not limited by scopes
+		ctx = context.WithValue(ctx, "scopes", creds.scopes)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// who made the request. scopes are only set for API keys, logins can do anything their user can
+type credentials struct {
+	user   database.User
+	scopes []string
+}
+
+func (c credentials) allows(scope string) bool {
+	return c.scopes == nil || auth.HasScope(c.scopes, scope)
+}
+
+// resolves the user from an API key or a bearer token. the token's session must still be
+// active, so logged out or revoked tokens are rejected before they expire
+func (cfg *ApiConfig) authenticate(r *http.Request) (credentials, error) {
+	if key, ok := auth.GetAPIKey(r.Header); ok {
+		return cfg.authenticateAPIKey(r.Context(), key)
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return credentials{}, fmt.Errorf("parsing header: %w", err)
+	}
+	userid, sessionid, err := auth.ValidateJWT(token, cfg.SECRET_JWT)
+	if err != nil {
+		return credentials{}, fmt.Errorf("validating token: %w", err)
+	}
+	active, err := cfg.DB.IsSessionActive(r.Context(), sessionid)
+	if err != nil {
+		return credentials{}, fmt.Errorf("checking session: %w", err)
+	}
+	if !active {
+		return credentials{}, fmt.Errorf("session %v is revoked or expired", sessionid)
+	}
+	user, err := cfg.DB.GetUser(r.Context(), userid)
+	if err != nil {
+		return credentials{}, fmt.Errorf("getting user: %w", err)
 	}
+	return credentials{user: user}, nil
 }
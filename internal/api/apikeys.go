@@ -0,0 +1,269 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/auth"
+	"github.com/o0n1x/mass-translate-server/internal/database"
+)
+
+// handles personal API keys for scripts and CI, sent as X-API-Key or "Authorization: ApiKey ..."
+
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	UserID     uuid.UUID  `json:"user_id"`
+	// only returned when the key is created
+	Key string `json:"key,omitempty"`
+}
+
+func (cfg *ApiConfig) GetMyAPIKeys(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(database.User)
+	cfg.apiKeysRespond(w, r, user.ID)
+}
+
+func (cfg *ApiConfig) CreateMyAPIKey(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(database.User)
+	cfg.createAPIKey(w, r, user)
+}
+
+func (cfg *ApiConfig) DeleteMyAPIKey(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(database.User)
+	cfg.deleteAPIKey(w, r, user.ID)
+}
+
+func (cfg *ApiConfig) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.userFromPath(w, r)
+	if !ok {
+		return
+	}
+	cfg.apiKeysRespond(w, r, user.ID)
+}
+
+func (cfg *ApiConfig) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.userFromPath(w, r)
+	if !ok {
+		return
+	}
+	err := cfg.checkCanManageUser(r, user.ID)
+	if err != nil {
+		errorRespond(w, err)
+		return
+	}
+	cfg.createAPIKey(w, r, user)
+}
+
+func (cfg *ApiConfig) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.userFromPath(w, r)
+	if !ok {
+		return
+	}
+	err := cfg.checkCanManageUser(r, user.ID)
+	if err != nil {
+		errorRespond(w, err)
+		return
+	}
+	cfg.deleteAPIKey(w, r, user.ID)
+}
+
+func (cfg *ApiConfig) apiKeysRespond(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	keys, err := cfg.DB.GetAPIKeys(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error retrieving API keys: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve API keys"))
+		return
+	}
+
+	returnedKeys := []APIKey{}
+	for _, key := range keys {
+		returnedKeys = append(returnedKeys, apiKeyResponse(key))
+	}
+
+	jsonRespond(w, 200, returnedKeys)
+}
+
+// the key itself is only returned here, afterwards only its prefix is known
+func (cfg *ApiConfig) createAPIKey(w http.ResponseWriter, r *http.Request, owner database.User) {
+	type parameters struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		errorRespond(w, apperr.ErrInvalidJSON)
+		return
+	}
+
+	if params.Name == "" {
+		errorRespond(w, apperr.ErrInvalidRequest.WithMessage("name is required"))
+		return
+	}
+	if len(params.Scopes) == 0 {
+		params.Scopes = []string{auth.ScopeUser}
+	}
+	permissions, err := cfg.DB.GetUserPermissions(r.Context(), owner.ID)
+	if err != nil {
+		log.Printf("Error getting permissions of user %v: %v", owner.ID, err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to create API key"))
+		return
+	}
+	// the admin scope is only useful to users with an admin permission
+	admin := slices.ContainsFunc(permissions, func(permission string) bool {
+		return auth.PermissionScope(permission) == auth.ScopeAdmin
+	})
+	// a key can only make keys with scopes it has itself
+	callerScopes, _ := r.Context().Value("scopes").([]string)
+	for _, scope := range params.Scopes {
+		if !auth.ValidScope(scope) {
+			errorRespond(w, apperr.ErrInvalidRequest.WithMessagef("unknown scope %q, use %s, %s or %s", scope, auth.ScopeTranslate, auth.ScopeUser, auth.ScopeAdmin))
+			return
+		}
+		if scope == auth.ScopeAdmin && !admin {
+			errorRespond(w, apperr.ErrForbidden.WithMessage("only users with an admin permission can have keys with the admin scope"))
+			return
+		}
+		if callerScopes != nil && !auth.HasScope(callerScopes, scope) {
+			errorRespond(w, apperr.ErrForbidden.WithMessagef("an API key can not create keys with the %s scope it does not have", scope))
+			return
+		}
+	}
+	expiresAt := sql.NullTime{}
+	if params.ExpiresAt != nil {
+		if !params.ExpiresAt.After(time.Now()) {
+			errorRespond(w, apperr.ErrInvalidRequest.WithMessage("expires_at must be in the future"))
+			return
+		}
+		expiresAt = sql.NullTime{Time: params.ExpiresAt.UTC(), Valid: true}
+	}
+
+	key, prefix, err := auth.MakeAPIKey()
+	if err != nil {
+		log.Printf("Error creating API key: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to create API key"))
+		return
+	}
+
+	created, err := cfg.DB.CreateAPIKey(r.Context(), database.CreateAPIKeyParams{
+		Name:      params.Name,
+		Prefix:    prefix,
+		KeyHash:   auth.HashToken(key),
+		Scopes:    params.Scopes,
+		ExpiresAt: expiresAt,
+		UserID:    owner.ID,
+	})
+	if err != nil {
+		log.Printf("Error creating API key: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to create API key"))
+		return
+	}
+
+	response := apiKeyResponse(created)
+	response.Key = key
+	jsonRespond(w, 201, response)
+}
+
+// keys of other users are reported as not found
+func (cfg *ApiConfig) deleteAPIKey(w http.ResponseWriter, r *http.Request, ownerID uuid.UUID) {
+	keyUUID, err := uuid.Parse(r.PathValue("key_id"))
+	if err != nil {
+		log.Printf("Error invalid API key ID: %v", err)
+		errorRespond(w, apperr.ErrInvalidID)
+		return
+	}
+
+	key, err := cfg.DB.GetAPIKey(r.Context(), keyUUID)
+	if err != nil || key.UserID != ownerID {
+		errorRespond(w, apperr.ErrAPIKeyNotFound)
+		return
+	}
+
+	err = cfg.DB.DeleteAPIKey(r.Context(), key.ID)
+	if err != nil {
+		log.Printf("Error deleting API key: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error deleting API key"))
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *ApiConfig) authenticateAPIKey(ctx context.Context, key string) (credentials, error) {
+	prefix, err := auth.APIKeyPrefix(key)
+	if err != nil {
+		return credentials{}, err
+	}
+	apiKey, err := cfg.DB.GetActiveAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		return credentials{}, fmt.Errorf("API key %s unknown or expired: %w", prefix, err)
+	}
+	if !auth.CheckAPIKey(key, apiKey.KeyHash) {
+		return credentials{}, fmt.Errorf("API key %s does not match", prefix)
+	}
+	user, err := cfg.DB.GetUser(ctx, apiKey.UserID)
+	if err != nil {
+		return credentials{}, fmt.Errorf("getting user: %w", err)
+	}
+
+	// failing to record the last use should not block the request
+	err = cfg.DB.TouchAPIKey(ctx, apiKey.ID)
+	if err != nil {
+		log.Printf("Error recording use of API key %s: %v", prefix, err)
+	}
+
+	return credentials{user: user, scopes: apiKey.Scopes}, nil
+}
+
+// resolves the {id} path value to a user, responding with 400 or 404 if it can not
+func (cfg *ApiConfig) userFromPath(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	userUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid user ID: %v", err)
+		errorRespond(w, apperr.ErrInvalidID)
+		return database.User{}, false
+	}
+
+	user, err := cfg.DB.GetUser(r.Context(), userUUID)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		errorRespond(w, apperr.ErrUserNotFound)
+		return database.User{}, false
+	}
+	return user, true
+}
+
+func apiKeyResponse(key database.ApiKey) APIKey {
+	response := APIKey{
+		ID:        key.ID,
+		CreatedAt: key.CreatedAt,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		UserID:    key.UserID,
+	}
+	if key.ExpiresAt.Valid {
+		response.ExpiresAt = &key.ExpiresAt.Time
+	}
+	if key.LastUsedAt.Valid {
+		response.LastUsedAt = &key.LastUsedAt.Time
+	}
+	return response
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/database"
+)
+
+// handles the audit trail of security relevant events
+
+// audit actions
+const (
+	AuditLoginLocked   = "login.locked"
+	AuditLoginUnlocked = "login.unlocked"
+)
+
+type AuditEvent struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Action    string     `json:"action"`
+	Target    string     `json:"target"`
+	IP        string     `json:"ip"`
+	Details   string     `json:"details,omitempty"`
+	ActorID   *uuid.UUID `json:"actor_id"`
+}
+
+// lists events newest first, optionally only one action
+func (cfg *ApiConfig) GetAuditEvents(w http.ResponseWriter, r *http.Request) {
+	limit, offset := getPagination(r)
+	params := database.GetAuditEventsParams{
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	}
+	if action := r.URL.Query().Get("action"); action != "" {
+		params.Action = sql.NullString{String: action, Valid: true}
+	}
+
+	events, err := cfg.DB.GetAuditEvents(r.Context(), params)
+	if err != nil {
+		log.Printf("Error retrieving audit events: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve audit events"))
+		return
+	}
+
+	returnedEvents := []AuditEvent{}
+	for _, event := range events {
+		returned := AuditEvent{
+			ID:        event.ID,
+			CreatedAt: event.CreatedAt,
+			Action:    event.Action,
+			Target:    event.Target,
+			IP:        event.Ip,
+			Details:   event.Details.String,
+		}
+		if event.ActorID.Valid {
+			returned.ActorID = &event.ActorID.UUID
+		}
+		returnedEvents = append(returnedEvents, returned)
+	}
+
+	jsonRespond(w, 200, returnedEvents)
+}
+
+// records an event. actor is who did it, nil for anonymous requests like a login. failures
+// are only logged so the audit trail never fails the request itself
+func (cfg *ApiConfig) audit(r *http.Request, action string, actor *uuid.UUID, target string, details string) {
+	ctx := context.WithoutCancel(r.Context())
+
+	params := database.CreateAuditEventParams{
+		Action:  action,
+		Target:  target,
+		Ip:      cfg.clientIP(r),
+		Details: sql.NullString{String: details, Valid: details != ""},
+	}
+	if actor != nil {
+		params.ActorID = uuid.NullUUID{UUID: *actor, Valid: true}
+	}
+
+	err := cfg.DB.CreateAuditEvent(ctx, params)
+	if err != nil {
+		log.Printf("Error storing audit event %s for %s: %v", action, target, err)
+	}
+}
+
+// the address the request came from. X-Forwarded-For is only believed when the request comes
+// from a trusted proxy, and then read from the right: each proxy appends the address it got
+// the request from, so the right-most hop that is not a trusted proxy is the client. anything
+// left of it could have been set by the client
+func (cfg *ApiConfig) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !cfg.trustedProxy(host) {
+		return host
+	}
+
+	hops := []string{}
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !cfg.trustedProxy(hops[i]) {
+			return hops[i]
+		}
+		host = hops[i]
+	}
+	// every hop is a proxy, the left-most is as close to the client as it gets
+	return host
+}
+
+func (cfg *ApiConfig) trustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, proxy := range cfg.TrustedProxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
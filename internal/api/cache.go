@@ -0,0 +1,134 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/o0n1x/mass-translate-package/format"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/cache"
+)
+
+// handles the admin view of the translation cache: its size and limits, the entries in it
+// and purging them
+
+type CacheStats struct {
+	Entries        int64     `json:"entries"`
+	SizeBytes      int64     `json:"size_bytes"`
+	Evictions      int64     `json:"evictions"`
+	MaxSizeBytes   int64     `json:"max_size_bytes"`
+	MaxEntryBytes  int64     `json:"max_entry_bytes"`
+	TTLs           CacheTTLs `json:"ttls"`
+	LargeFileBytes int64     `json:"large_file_bytes"`
+}
+
+type CacheTTLs struct {
+	Text      string `json:"text"`
+	File      string `json:"file"`
+	LargeFile string `json:"large_file"`
+}
+
+type CacheEntry struct {
+	Key        string    `json:"key"`
+	Provider   string    `json:"provider"`
+	ReqType    string    `json:"req_type"`
+	SourceLang string    `json:"source_lang"`
+	TargetLang string    `json:"target_lang"`
+	SizeBytes  int64     `json:"size_bytes"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func (cfg *ApiConfig) GetCacheStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := cache.GetStats(r.Context(), cfg.Redis)
+	if err != nil {
+		log.Printf("Error retrieving cache stats: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve cache stats"))
+		return
+	}
+
+	jsonRespond(w, 200, CacheStats{
+		Entries:       stats.Entries,
+		SizeBytes:     stats.Size,
+		Evictions:     stats.Evictions,
+		MaxSizeBytes:  cache.MaxSize,
+		MaxEntryBytes: cache.MaxEntrySize,
+		TTLs: CacheTTLs{
+			Text:      cache.TTLs[format.Text].String(),
+			File:      cache.TTLs[format.File].String(),
+			LargeFile: cache.LargeFileTTL.String(),
+		},
+		LargeFileBytes: cache.LargeFileSize,
+	})
+}
+
+// entries expiring first come first, filtered like PurgeCache
+func (cfg *ApiConfig) GetCacheEntries(w http.ResponseWriter, r *http.Request) {
+	entries, err := cache.GetEntries(r.Context(), cfg.Redis, cacheFilter(r))
+	if err != nil {
+		log.Printf("Error retrieving cache entries: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve cache entries"))
+		return
+	}
+
+	limit, offset := getPagination(r)
+	entries = entries[min(offset, len(entries)):min(offset+limit, len(entries))]
+
+	returnedEntries := []CacheEntry{}
+	for _, entry := range entries {
+		returnedEntries = append(returnedEntries, CacheEntry{
+			Key:        entry.Key,
+			Provider:   entry.Provider,
+			ReqType:    entry.ReqType.String(),
+			SourceLang: entry.SourceLang,
+			TargetLang: entry.TargetLang,
+			SizeBytes:  entry.Size,
+			ExpiresAt:  entry.ExpiresAt,
+		})
+	}
+
+	jsonRespond(w, 200, returnedEntries)
+}
+
+// without filters the whole cache is purged
+func (cfg *ApiConfig) PurgeCache(w http.ResponseWriter, r *http.Request) {
+	filter := cacheFilter(r)
+	purged, err := cache.Purge(r.Context(), cfg.Redis, filter)
+	if err != nil {
+		log.Printf("Error purging cache: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error purging cache"))
+		return
+	}
+	log.Printf("purged %d cache entries matching %+v", purged, filter)
+
+	jsonRespond(w, 200, struct {
+		Purged int64 `json:"purged"`
+	}{
+		Purged: purged,
+	})
+}
+
+// only keys listed by GetCacheEntries can be removed, anything else in redis is left alone
+func (cfg *ApiConfig) InvalidateCacheKey(w http.ResponseWriter, r *http.Request) {
+	found, err := cache.Invalidate(r.Context(), cfg.Redis, r.PathValue("key"))
+	if err != nil {
+		log.Printf("Error invalidating cache key: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error invalidating cache key"))
+		return
+	}
+	if !found {
+		errorRespond(w, apperr.ErrCacheKeyNotFound)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func cacheFilter(r *http.Request) cache.Filter {
+	query := r.URL.Query()
+	return cache.Filter{
+		Provider:   query.Get("provider"),
+		SourceLang: query.Get("source_lang"),
+		TargetLang: query.Get("target_lang"),
+	}
+}
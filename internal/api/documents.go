@@ -0,0 +1,371 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-package/format"
+	"github.com/o0n1x/mass-translate-package/lang"
+	"github.com/o0n1x/mass-translate-package/provider"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/auth"
+	"github.com/o0n1x/mass-translate-server/internal/cache"
+	"github.com/o0n1x/mass-translate-server/internal/database"
+	"github.com/o0n1x/mass-translate-server/internal/glossary"
+	"github.com/o0n1x/mass-translate-server/internal/subtitles"
+)
+
+// handles async document translation jobs
+
+// document job statuses
+const (
+	DocumentPending     = "pending"
+	DocumentTranslating = "translating"
+	DocumentDone        = "done"
+	DocumentError       = "error"
+)
+
+// upper bound for a single background translation
+const documentJobTimeout = time.Minute * 30
+
+type Document struct {
+	ID         uuid.UUID `json:"document_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Provider   string    `json:"provider"`
+	FileName   string    `json:"file_name"`
+	SourceLang string    `json:"source_lang"`
+	TargetLang string    `json:"target_lang"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func (cfg *ApiConfig) CreateDocument(w http.ResponseWriter, r *http.Request) {
+	client, ok := cfg.providerFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	file, fields, ok := cfg.receiveUpload(w, r, client)
+	if !ok {
+		return
+	}
+	// once the job starts it owns the file and removes it when done
+	started := false
+	defer func() {
+		if !started {
+			file.Close()
+		}
+	}()
+
+	if fields["target_lang"] == "" {
+		errorRespond(w, apperr.ErrInvalidTargetLang.WithMessage("invalid form no target language"))
+		return
+	}
+
+	user := r.Context().Value("user").(database.User)
+
+	// the exact check happens in the job once we know it is not cached,
+	// this only turns away users who have nothing left
+	err := cfg.checkQuota(r.Context(), user.ID, 0, 0)
+	if err != nil {
+		log.Printf("Quota check for user %v: %v", user.ID, err)
+		errorRespond(w, err)
+		return
+	}
+
+	req := provider.Request{
+		ReqType:  format.File,
+		FileName: file.name,
+		From:     lang.Language(fields["source_lang"]),
+		To:       lang.Language(fields["target_lang"]),
+	}
+
+	g, err := cfg.glossaryForRequest(r, user, fields["glossary_id"], &req)
+	if err != nil {
+		log.Printf("Error loading glossary: %v", err)
+		errorRespond(w, err)
+		return
+	}
+	opts, err := subtitleOptions(fields)
+	if err != nil {
+		errorRespond(w, err)
+		return
+	}
+
+	// the name the translated file is downloaded as, subtitles may be converted
+	doc, err := cfg.DB.CreateDocument(r.Context(), database.CreateDocumentParams{
+		Provider: string(client.Name()),
+		FileName: subtitles.OutputName(req.FileName, opts),
+		FromLang: req.From.String(),
+		ToLang:   req.To.String(),
+		Status:   DocumentPending,
+		UserID:   user.ID,
+	})
+	if err != nil {
+		log.Printf("Error creating document: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to create document"))
+		return
+	}
+
+	// the request context is cancelled once we respond, so the job gets its own
+	started = true
+	go cfg.processDocument(doc.ID, user.ID, client, req, file, g, opts)
+
+	jsonRespond(w, 202, struct {
+		ID     uuid.UUID `json:"document_id"`
+		Status string    `json:"status"`
+	}{
+		ID:     doc.ID,
+		Status: doc.Status,
+	})
+}
+
+func (cfg *ApiConfig) GetDocument(w http.ResponseWriter, r *http.Request) {
+	doc, ok := cfg.documentFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	jsonRespond(w, 200, Document{
+		ID:         doc.ID,
+		CreatedAt:  doc.CreatedAt,
+		UpdatedAt:  doc.UpdatedAt,
+		Provider:   doc.Provider,
+		FileName:   doc.FileName,
+		SourceLang: doc.FromLang,
+		TargetLang: doc.ToLang,
+		Status:     doc.Status,
+		Error:      doc.Error.String,
+	})
+}
+
+func (cfg *ApiConfig) DownloadDocument(w http.ResponseWriter, r *http.Request) {
+	doc, ok := cfg.documentFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if doc.Status != DocumentDone {
+		errorRespond(w, apperr.ErrDocumentNotReady.WithMessage("document is not ready, status: "+doc.Status))
+		return
+	}
+
+	content, found, err := cache.GetDocument(r.Context(), cfg.Redis, doc.ID)
+	if err != nil {
+		log.Printf("Error retrieving document %v: %v", doc.ID, err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve document"))
+		return
+	}
+	if !found {
+		errorRespond(w, apperr.ErrDocumentExpired)
+		return
+	}
+
+	fileRespond(w, r, content, doc.FileName, doc.UpdatedAt)
+}
+
+func (cfg *ApiConfig) DeleteDocument(w http.ResponseWriter, r *http.Request) {
+	doc, ok := cfg.documentFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// the row goes first, a job still translating the document checks for it after storing
+	// the file so one of the two always removes the file
+	err := cfg.DB.DeleteDocument(r.Context(), doc.ID)
+	if err != nil {
+		log.Printf("Error deleting document: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error deleting document"))
+		return
+	}
+
+	err = cache.DeleteDocument(r.Context(), cfg.Redis, doc.ID)
+	if err != nil {
+		log.Printf("Error deleting document %v from cache: %v", doc.ID, err)
+	}
+
+	w.WriteHeader(204)
+}
+
+// looks up the document in the path and makes sure the caller owns it.
+// documents of other users or other providers are reported as not found, other users'
+// unless the caller is an admin
+func (cfg *ApiConfig) documentFromRequest(w http.ResponseWriter, r *http.Request) (database.Document, bool) {
+	docUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid document ID: %v", err)
+		errorRespond(w, apperr.ErrInvalidID)
+		return database.Document{}, false
+	}
+
+	doc, err := cfg.DB.GetDocument(r.Context(), docUUID)
+	if err != nil {
+		log.Printf("Error retrieving document: %v", err)
+		errorRespond(w, apperr.ErrDocumentNotFound)
+		return database.Document{}, false
+	}
+	// documents only live under the provider they were submitted to
+	if !strings.EqualFold(doc.Provider, r.PathValue("provider")) {
+		log.Printf("document %v requested under provider %s", doc.ID, r.PathValue("provider"))
+		errorRespond(w, apperr.ErrDocumentNotFound)
+		return database.Document{}, false
+	}
+
+	user := r.Context().Value("user").(database.User)
+	if doc.UserID != user.ID && !hasPermission(r, auth.PermDocumentsAdmin) {
+		log.Printf("user %v attempted to access document %v", user.ID, doc.ID)
+		errorRespond(w, apperr.ErrDocumentNotFound)
+		return database.Document{}, false
+	}
+
+	return doc, true
+}
+
+// opts only apply to subtitles
+func (cfg *ApiConfig) processDocument(id uuid.UUID, userID uuid.UUID, client provider.Client, req provider.Request, file *upload, g *glossary.Glossary, opts subtitles.Options) {
+	defer file.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), documentJobTimeout)
+	defer cancel()
+
+	cfg.setDocumentStatus(ctx, id, DocumentTranslating, nil)
+
+	if translatedOnServer(req.FileName) {
+		res, err := cfg.translateOnServer(ctx, userID, client, req, file, g, opts)
+		if err == nil {
+			err = cache.SetDocument(ctx, cfg.Redis, id, res.file)
+		}
+		if err != nil {
+			log.Printf("Error translating document %v: %v", id, err)
+			cfg.setDocumentStatus(ctx, id, DocumentError, err)
+			return
+		}
+		cfg.documentDone(ctx, id)
+		return
+	}
+
+	glossaryVersion := ""
+	if g != nil {
+		glossaryVersion = g.Version()
+	}
+
+	cached, hit, err := cache.GetFile(ctx, cfg.Redis, cache.NewKey(client, req).WithGlossary(glossaryVersion), file.hash)
+	if err != nil {
+		log.Printf("cache error: %v", err)
+	}
+	if hit {
+		cfg.recordTranslationUsage(ctx, userID, client.Name(), req, 0, file.size, true, nil)
+		err = cache.SetDocumentFrom(ctx, cfg.Redis, id, cached)
+	} else {
+		req.Binary, err = file.bytes()
+		if err != nil {
+			log.Printf("Error reading file of document %v: %v", id, err)
+			cfg.setDocumentStatus(ctx, id, DocumentError, err)
+			return
+		}
+
+		chars, bytes := requestUsage(req)
+		err = cfg.checkQuota(ctx, userID, chars, bytes)
+		if err != nil {
+			log.Printf("Quota check for document %v: %v", id, err)
+			if errors.Is(err, apperr.ErrQuotaExceeded) {
+				cfg.recordTranslation(ctx, userID, client.Name(), req, false, err)
+			}
+			cfg.setDocumentStatus(ctx, id, DocumentError, err)
+			return
+		}
+
+		var res provider.Response
+		var served provider.Client
+		res, served, err = cfg.translateWithFallback(ctx, client, req, g)
+		cfg.recordTranslation(ctx, userID, served.Name(), req, false, err)
+		if err != nil {
+			log.Printf("Error translating document %v: %v", id, err)
+			cfg.setDocumentStatus(ctx, id, DocumentError, err)
+			return
+		}
+
+		err = cache.SetFile(ctx, cfg.Redis, cache.NewKey(served, req).WithGlossary(glossaryVersion), file.hash, res.Binary)
+		if err != nil {
+			log.Printf("cache set error: %v", err)
+		}
+
+		err = cache.SetDocument(ctx, cfg.Redis, id, res.Binary)
+	}
+	if err != nil {
+		log.Printf("Error storing document %v: %v", id, err)
+		cfg.setDocumentStatus(ctx, id, DocumentError, err)
+		return
+	}
+
+	cfg.documentDone(ctx, id)
+}
+
+// marks the document done. if it was deleted while it was translating the stored file has
+// no row left to expire with, so it is removed
+func (cfg *ApiConfig) documentDone(ctx context.Context, id uuid.UUID) {
+	ctx = context.WithoutCancel(ctx)
+	_, err := cfg.DB.UpdateDocumentStatus(ctx, database.UpdateDocumentStatusParams{
+		ID:     id,
+		Status: DocumentDone,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("document %v was deleted while translating", id)
+		err = cache.DeleteDocument(ctx, cfg.Redis, id)
+		if err != nil {
+			log.Printf("Error deleting document %v from cache: %v", id, err)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating document %v status to %s: %v", id, DocumentDone, err)
+	}
+}
+
+// marks jobs a stopped server left pending or translating as failed, at startup and then
+// every documentJobTimeout. a job gives up after documentJobTimeout, so only documents
+// untouched for longer are failed and jobs running on other servers are left alone
+func (cfg *ApiConfig) FailStaleDocuments() {
+	for {
+		failed, err := cfg.DB.FailStaleDocuments(context.Background(), database.FailStaleDocumentsParams{
+			Error:     sql.NullString{String: "translation interrupted, please try again", Valid: true},
+			UpdatedAt: time.Now().Add(-documentJobTimeout),
+		})
+		if err != nil {
+			log.Printf("Error failing stale documents: %v", err)
+		}
+		if failed > 0 {
+			log.Printf("marked %d interrupted documents as failed", failed)
+		}
+		time.Sleep(documentJobTimeout)
+	}
+}
+
+// the error is shown to the document's owner, the caller logs the full error. the status is
+// written even once the job ran out of time
+func (cfg *ApiConfig) setDocumentStatus(ctx context.Context, id uuid.UUID, status string, jobErr error) {
+	errText := sql.NullString{}
+	switch {
+	// providers do not always wrap the context error, so the context is asked
+	case jobErr != nil && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		errText = sql.NullString{String: fmt.Sprintf("translation took longer than %v", documentJobTimeout), Valid: true}
+	case jobErr != nil:
+		errText = sql.NullString{String: failureMessage(jobErr), Valid: true}
+	}
+	ctx = context.WithoutCancel(ctx)
+	_, err := cfg.DB.UpdateDocumentStatus(ctx, database.UpdateDocumentStatusParams{
+		ID:     id,
+		Status: status,
+		Error:  errText,
+	})
+	if err != nil {
+		log.Printf("Error updating document %v status to %s: %v", id, status, err)
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/o0n1x/mass-translate-package/provider"
+	"github.com/o0n1x/mass-translate-server/internal/formats"
+)
+
+// handles listing the file types each enabled provider accepts
+
+type FileFormat struct {
+	Name       string   `json:"name"`
+	Extensions []string `json:"extensions"`
+	MIMEType   string   `json:"mime_type"`
+}
+
+type ProviderFormats struct {
+	Provider provider.Provider `json:"provider"`
+	Path     string            `json:"path"`
+	Formats  []FileFormat      `json:"formats"`
+}
+
+func (cfg *ApiConfig) GetFormats(w http.ResponseWriter, r *http.Request) {
+	providers := []ProviderFormats{}
+	for name := range cfg.Providers {
+		providers = append(providers, ProviderFormats{
+			Provider: name,
+			Path:     providerPath(name),
+			Formats:  cfg.providerFormats(name),
+		})
+	}
+	slices.SortFunc(providers, func(a, b ProviderFormats) int {
+		return strings.Compare(string(a.Provider), string(b.Provider))
+	})
+
+	jsonRespond(w, 200, providers)
+}
+
+// the registered formats with only the extensions the provider accepts
+func (cfg *ApiConfig) providerFormats(name provider.Provider) []FileFormat {
+	accepted := []FileFormat{}
+	for _, f := range formats.All() {
+		extensions := []string{}
+		for _, ext := range f.Extensions {
+			if cfg.AllowedExtensions[name][ext] {
+				extensions = append(extensions, ext)
+			}
+		}
+		if len(extensions) == 0 {
+			continue
+		}
+		accepted = append(accepted, FileFormat{
+			Name:       f.Name,
+			Extensions: extensions,
+			MIMEType:   f.MIMEType,
+		})
+	}
+	return accepted
+}
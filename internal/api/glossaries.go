@@ -0,0 +1,417 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-package/lang"
+	"github.com/o0n1x/mass-translate-package/provider"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/auth"
+	"github.com/o0n1x/mass-translate-server/internal/database"
+	"github.com/o0n1x/mass-translate-server/internal/glossary"
+)
+
+// handles glossary management and lookup for translations
+
+const MAXGLOSSARYENTRIES = 5000
+
+type Glossary struct {
+	ID             uuid.UUID        `json:"id"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+	Name           string           `json:"name"`
+	SourceLang     string           `json:"source_lang"`
+	TargetLang     string           `json:"target_lang"`
+	Shared         bool             `json:"shared"`
+	UserID         uuid.UUID        `json:"user_id"`
+	OrganizationID *uuid.UUID       `json:"organization_id"`
+	Entries        []glossary.Entry `json:"entries,omitempty"`
+}
+
+func (cfg *ApiConfig) CreateGlossary(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name         string           `json:"name"`
+		SourceLang   string           `json:"source_lang"`
+		TargetLang   string           `json:"target_lang"`
+		Shared       bool             `json:"shared"`
+		Organization bool             `json:"organization"`
+		Entries      []glossary.Entry `json:"entries"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		errorRespond(w, apperr.ErrInvalidJSON)
+		return
+	}
+
+	user := r.Context().Value("user").(database.User)
+
+	if params.Name == "" || params.SourceLang == "" || params.TargetLang == "" {
+		errorRespond(w, apperr.ErrInvalidRequest.WithMessage("name, source_lang and target_lang are required"))
+		return
+	}
+	// shared glossaries are used by everyone so only admins may publish them
+	if params.Shared && !hasPermission(r, auth.PermGlossariesAdmin) {
+		errorRespond(w, apperr.ErrForbidden.WithMessage("only admins can create shared glossaries"))
+		return
+	}
+	entries, err := validateGlossaryEntries(params.Entries)
+	if err != nil {
+		errorRespond(w, err)
+		return
+	}
+	orgID := uuid.NullUUID{}
+	if params.Organization {
+		member, ok, err := cfg.membership(r.Context(), user.ID)
+		if err != nil {
+			log.Printf("Error retrieving membership of user %v: %v", user.ID, err)
+			errorRespond(w, apperr.ErrInternal.WithMessage("Failed to create glossary"))
+			return
+		}
+		if !ok {
+			errorRespond(w, apperr.ErrInvalidRequest.WithMessage("user is not in an organization"))
+			return
+		}
+		orgID = uuid.NullUUID{UUID: member.OrganizationID, Valid: true}
+	}
+
+	g, err := cfg.DB.CreateGlossary(r.Context(), database.CreateGlossaryParams{
+		Name:           params.Name,
+		SourceLang:     strings.ToUpper(params.SourceLang),
+		TargetLang:     strings.ToUpper(params.TargetLang),
+		Shared:         params.Shared,
+		UserID:         user.ID,
+		OrganizationID: orgID,
+	})
+	if err != nil {
+		log.Printf("Error creating glossary: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to create glossary"))
+		return
+	}
+
+	err = cfg.replaceGlossaryEntries(r.Context(), g.ID, entries)
+	if err != nil {
+		log.Printf("Error creating glossary entries: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to create glossary"))
+		return
+	}
+
+	jsonRespond(w, 201, glossaryResponse(g, entries))
+}
+
+func (cfg *ApiConfig) GetGlossaries(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(database.User)
+
+	glossaryID := r.PathValue("id")
+	if glossaryID != "" {
+		g, ok := cfg.glossaryFromRequest(w, r, false)
+		if !ok {
+			return
+		}
+		entries, err := cfg.getGlossaryEntries(r.Context(), g.ID)
+		if err != nil {
+			log.Printf("Error retrieving glossary entries: %v", err)
+			errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve glossary"))
+			return
+		}
+		jsonRespond(w, 200, glossaryResponse(g, entries))
+		return
+	}
+
+	limit, offset := getPagination(r)
+
+	member, ok, err := cfg.membership(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error retrieving membership of user %v: %v", user.ID, err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve glossaries"))
+		return
+	}
+
+	glossaries, err := cfg.DB.GetGlossaries(r.Context(), database.GetGlossariesParams{
+		UserID:         user.ID,
+		OrganizationID: uuid.NullUUID{UUID: member.OrganizationID, Valid: ok},
+		Limit:          int32(limit),
+		Offset:         int32(offset),
+	})
+	if err != nil {
+		log.Printf("Error retrieving glossaries: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve glossaries"))
+		return
+	}
+
+	returnedGlossaries := []Glossary{}
+	for _, g := range glossaries {
+		returnedGlossaries = append(returnedGlossaries, glossaryResponse(g, nil))
+	}
+
+	jsonRespond(w, 200, returnedGlossaries)
+}
+
+func (cfg *ApiConfig) UpdateGlossary(w http.ResponseWriter, r *http.Request) {
+	g, ok := cfg.glossaryFromRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	// entries replace the current ones when given, languages are fixed after creation
+	type parameters struct {
+		Name    *string           `json:"name,omitempty"`
+		Shared  *bool             `json:"shared,omitempty"`
+		Entries *[]glossary.Entry `json:"entries,omitempty"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		errorRespond(w, apperr.ErrInvalidJSON)
+		return
+	}
+
+	if params.Name == nil {
+		params.Name = &g.Name
+	}
+	if params.Shared == nil {
+		params.Shared = &g.Shared
+	}
+	if *params.Shared != g.Shared && !hasPermission(r, auth.PermGlossariesAdmin) {
+		errorRespond(w, apperr.ErrForbidden.WithMessage("only admins can share glossaries"))
+		return
+	}
+
+	if params.Entries != nil {
+		entries, err := validateGlossaryEntries(*params.Entries)
+		if err != nil {
+			errorRespond(w, err)
+			return
+		}
+		err = cfg.replaceGlossaryEntries(r.Context(), g.ID, entries)
+		if err != nil {
+			log.Printf("Error updating glossary entries: %v", err)
+			errorRespond(w, apperr.ErrInternal.WithMessage("error updating glossary"))
+			return
+		}
+	}
+
+	// always bumps updated_at, which changes the glossary version used in cache keys
+	updated, err := cfg.DB.UpdateGlossary(r.Context(), database.UpdateGlossaryParams{
+		ID:     g.ID,
+		Name:   *params.Name,
+		Shared: *params.Shared,
+	})
+	if err != nil {
+		log.Printf("Error updating glossary: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error updating glossary"))
+		return
+	}
+
+	entries, err := cfg.getGlossaryEntries(r.Context(), g.ID)
+	if err != nil {
+		log.Printf("Error retrieving glossary entries: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error updating glossary"))
+		return
+	}
+
+	jsonRespond(w, 200, glossaryResponse(updated, entries))
+}
+
+func (cfg *ApiConfig) DeleteGlossary(w http.ResponseWriter, r *http.Request) {
+	g, ok := cfg.glossaryFromRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	// copies are looked up on the glossary row, so they go first
+	for _, client := range cfg.GlossaryClients {
+		if remover, ok := client.(glossary.Remover); ok {
+			remover.DeleteGlossary(r.Context(), g.ID)
+		}
+	}
+
+	err := cfg.DB.DeleteGlossary(r.Context(), g.ID)
+	if err != nil {
+		log.Printf("Error deleting glossary: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error deleting glossary"))
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// looks up the glossary in the path. anyone may read their own, their organization's and
+// shared glossaries, only the owner, an admin or an admin of the glossary's organization
+// may change them
+func (cfg *ApiConfig) glossaryFromRequest(w http.ResponseWriter, r *http.Request, write bool) (database.Glossary, bool) {
+	glossaryUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid glossary ID: %v", err)
+		errorRespond(w, apperr.ErrInvalidID)
+		return database.Glossary{}, false
+	}
+
+	g, err := cfg.DB.GetGlossary(r.Context(), glossaryUUID)
+	if err != nil {
+		log.Printf("Error retrieving glossary: %v", err)
+		errorRespond(w, apperr.ErrGlossaryNotFound)
+		return database.Glossary{}, false
+	}
+
+	user := r.Context().Value("user").(database.User)
+	read, owner, err := cfg.glossaryAccess(r, user, g)
+	if err != nil {
+		log.Printf("Error checking access to glossary %v: %v", g.ID, err)
+		errorRespond(w, apperr.ErrInternal)
+		return database.Glossary{}, false
+	}
+	if !read {
+		errorRespond(w, apperr.ErrGlossaryNotFound)
+		return database.Glossary{}, false
+	}
+	if write && !owner {
+		log.Printf("user %v attempted to modify glossary %v", user.ID, g.ID)
+		errorRespond(w, apperr.ErrForbidden)
+		return database.Glossary{}, false
+	}
+
+	return g, true
+}
+
+// loads a glossary for a translation request. an empty id means no glossary.
+// a missing source language is taken from the glossary since providers need it
+func (cfg *ApiConfig) glossaryForRequest(r *http.Request, user database.User, glossaryID string, req *provider.Request) (*glossary.Glossary, error) {
+	if glossaryID == "" {
+		return nil, nil
+	}
+	ctx := r.Context()
+	glossaryUUID, err := uuid.Parse(glossaryID)
+	if err != nil {
+		return nil, apperr.ErrGlossaryNotFound
+	}
+	g, err := cfg.DB.GetGlossary(ctx, glossaryUUID)
+	if err != nil {
+		return nil, apperr.ErrGlossaryNotFound
+	}
+	read, _, err := cfg.glossaryAccess(r, user, g)
+	if err != nil {
+		return nil, err
+	}
+	if !read {
+		return nil, apperr.ErrGlossaryNotFound
+	}
+
+	entries, err := cfg.getGlossaryEntries(ctx, g.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := glossary.Glossary{
+		ID:         g.ID,
+		UpdatedAt:  g.UpdatedAt,
+		SourceLang: lang.Language(g.SourceLang),
+		TargetLang: lang.Language(g.TargetLang),
+		Entries:    entries,
+	}
+	if req.From == "" || req.From == lang.AutoDetect {
+		req.From = result.SourceLang
+	}
+	if !result.Matches(req.From, req.To) {
+		return nil, apperr.ErrGlossaryMismatch.WithMessagef("glossary is %s to %s", g.SourceLang, g.TargetLang)
+	}
+	return &result, nil
+}
+
+func (cfg *ApiConfig) getGlossaryEntries(ctx context.Context, glossaryID uuid.UUID) ([]glossary.Entry, error) {
+	rows, err := cfg.DB.GetGlossaryEntries(ctx, glossaryID)
+	if err != nil {
+		return nil, err
+	}
+	entries := []glossary.Entry{}
+	for _, row := range rows {
+		entries = append(entries, glossary.Entry{Source: row.Source, Target: row.Target})
+	}
+	return entries, nil
+}
+
+func (cfg *ApiConfig) replaceGlossaryEntries(ctx context.Context, glossaryID uuid.UUID, entries []glossary.Entry) error {
+	sources := []string{}
+	targets := []string{}
+	for _, entry := range entries {
+		sources = append(sources, entry.Source)
+		targets = append(targets, entry.Target)
+	}
+	return cfg.DB.ReplaceGlossaryEntries(ctx, database.ReplaceGlossaryEntriesParams{
+		Sources:    sources,
+		Targets:    targets,
+		GlossaryID: glossaryID,
+	})
+}
+
+// terms are trimmed and must be unique. tabs and newlines are rejected since
+// providers exchange glossaries as TSV
+func validateGlossaryEntries(entries []glossary.Entry) ([]glossary.Entry, error) {
+	if len(entries) > MAXGLOSSARYENTRIES {
+		return nil, apperr.ErrInvalidRequest.WithMessagef("a glossary can have at most %d entries", MAXGLOSSARYENTRIES)
+	}
+	seen := map[string]bool{}
+	valid := []glossary.Entry{}
+	for _, entry := range entries {
+		entry.Source = strings.TrimSpace(entry.Source)
+		entry.Target = strings.TrimSpace(entry.Target)
+		if entry.Source == "" || entry.Target == "" {
+			return nil, apperr.ErrInvalidRequest.WithMessage("glossary entries need a source and a target")
+		}
+		if strings.ContainsAny(entry.Source+entry.Target, "\t\r\n") {
+			return nil, apperr.ErrInvalidRequest.WithMessage("glossary entries can not contain tabs or newlines")
+		}
+		if seen[entry.Source] {
+			return nil, apperr.ErrInvalidRequest.WithMessagef("duplicate glossary entry: %s", entry.Source)
+		}
+		seen[entry.Source] = true
+		valid = append(valid, entry)
+	}
+	return valid, nil
+}
+
+// whether the user may use the glossary and whether they may change it
+func (cfg *ApiConfig) glossaryAccess(r *http.Request, user database.User, g database.Glossary) (bool, bool, error) {
+	if g.UserID == user.ID || hasPermission(r, auth.PermGlossariesAdmin) {
+		return true, true, nil
+	}
+	if !g.OrganizationID.Valid {
+		return g.Shared, false, nil
+	}
+	member, ok, err := cfg.membership(r.Context(), user.ID)
+	if err != nil {
+		return false, false, err
+	}
+	inOrg := ok && member.OrganizationID == g.OrganizationID.UUID
+	return g.Shared || inOrg, inOrg && member.IsAdmin, nil
+}
+
+func glossaryResponse(g database.Glossary, entries []glossary.Entry) Glossary {
+	response := Glossary{
+		ID:         g.ID,
+		CreatedAt:  g.CreatedAt,
+		UpdatedAt:  g.UpdatedAt,
+		Name:       g.Name,
+		SourceLang: g.SourceLang,
+		TargetLang: g.TargetLang,
+		Shared:     g.Shared,
+		UserID:     g.UserID,
+		Entries:    entries,
+	}
+	if g.OrganizationID.Valid {
+		response.OrganizationID = &g.OrganizationID.UUID
+	}
+	return response
+}
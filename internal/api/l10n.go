@@ -0,0 +1,50 @@
+package api
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-package/format"
+	"github.com/o0n1x/mass-translate-package/provider"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/glossary"
+	"github.com/o0n1x/mass-translate-server/internal/l10n"
+)
+
+// handles localization files such as JSON bundles or strings.xml, their values are translated
+// with keys, comments and placeholders left as they are
+
+func (cfg *ApiConfig) translateLocalization(ctx context.Context, userID uuid.UUID, client provider.Client, req provider.Request, file *upload, g *glossary.Glossary) (serverFileResult, error) {
+	result := serverFileResult{segmentResult: segmentResult{served: client}, filename: req.FileName}
+	data, err := file.bytes()
+	if err != nil {
+		log.Printf("Error reading file: %v", err)
+		return result, apperr.ErrInternal.WithMessage("failed to read file")
+	}
+	parsed, err := l10n.Parse(req.FileName, data)
+	if err != nil {
+		return result, apperr.ErrInvalidRequest.WithMessagef("%s: %v", req.FileName, err)
+	}
+
+	textReq := provider.Request{ReqType: format.Text, Text: parsed.Texts(), From: req.From, To: req.To}
+	if len(textReq.Text) == 0 {
+		result.file = data
+		return result, nil
+	}
+	result.segmentResult, err = cfg.translateSegments(ctx, userID, client, textReq, g)
+	if err != nil {
+		return result, err
+	}
+
+	result.file, err = parsed.Build(result.translations)
+	if err != nil {
+		log.Printf("Error rebuilding %s: %v", req.FileName, err)
+		return result, apperr.ErrInternal.WithMessage("failed to rebuild file")
+	}
+	result.untranslated = parsed.Lost
+	if parsed.Lost > 0 {
+		log.Printf("%d values of %s left untranslated, the provider lost their placeholders", parsed.Lost, req.FileName)
+	}
+	return result, nil
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-package/provider"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/database"
+)
+
+// handles request and log records of translations
+
+// UserID is null and Email empty once the user is deleted, their requests are kept for usage
+type LogEntry struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	IsSuccessful   bool       `json:"is_successful"`
+	Cached         bool       `json:"cached"`
+	Error          string     `json:"error,omitempty"`
+	RequestID      uuid.UUID  `json:"request_id"`
+	Provider       string     `json:"provider"`
+	ReqType        string     `json:"req_type"`
+	SourceLang     string     `json:"source_lang"`
+	TargetLang     string     `json:"target_lang"`
+	UserID         *uuid.UUID `json:"user_id"`
+	Email          string     `json:"email"`
+	OrganizationID *uuid.UUID `json:"organization_id"`
+}
+
+func (cfg *ApiConfig) GetLogs(w http.ResponseWriter, r *http.Request) {
+	logID := r.PathValue("id")
+	if logID != "" {
+		logUUID, err := uuid.Parse(logID)
+		if err != nil {
+			log.Printf("Error invalid log ID: %v", err)
+			errorRespond(w, apperr.ErrInvalidID)
+			return
+		}
+
+		entry, err := cfg.DB.GetLog(r.Context(), logUUID)
+		if err != nil {
+			log.Printf("Error retrieving log: %v", err)
+			errorRespond(w, apperr.ErrLogNotFound)
+			return
+		}
+
+		jsonRespond(w, 200, logEntryFromRow(database.GetLogsRow(entry)))
+		return
+	}
+
+	params, err := getLogFilters(r)
+	if err != nil {
+		log.Printf("Error invalid log filter: %v", err)
+		errorRespond(w, err)
+		return
+	}
+
+	limit, offset := getPagination(r)
+	params.Limit = int32(limit)
+	params.Offset = int32(offset)
+
+	entries, err := cfg.DB.GetLogs(r.Context(), params)
+	if err != nil {
+		log.Printf("Error retrieving logs: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve logs"))
+		return
+	}
+
+	returnedLogs := []LogEntry{}
+
+	for _, entry := range entries {
+		returnedLogs = append(returnedLogs, logEntryFromRow(entry))
+	}
+
+	jsonRespond(w, 200, returnedLogs)
+}
+
+// stores who translated what and how it went. failures here are only logged
+// so bookkeeping never fails an otherwise good translation
+func (cfg *ApiConfig) recordTranslation(ctx context.Context, userID uuid.UUID, clienttype provider.Provider, req provider.Request, cached bool, translateErr error) {
+	chars, bytes := requestUsage(req)
+	cfg.recordTranslationUsage(ctx, userID, clienttype, req, chars, bytes, cached, translateErr)
+}
+
+// for uploads that were not read into req.Binary, e.g. cache hits
+func (cfg *ApiConfig) recordTranslationUsage(ctx context.Context, userID uuid.UUID, clienttype provider.Provider, req provider.Request, chars int64, bytes int64, cached bool, translateErr error) {
+	// the record should be written even if the client already hung up
+	ctx = context.WithoutCancel(ctx)
+
+	request, err := cfg.DB.CreateRequest(ctx, database.CreateRequestParams{
+		Provider:  string(clienttype),
+		ReqType:   req.ReqType.String(),
+		FromLang:  req.From.String(),
+		ToLang:    req.To.String(),
+		UserID:    userID,
+		CharCount: chars,
+		ByteCount: bytes,
+	})
+	if err != nil {
+		log.Printf("Error storing request: %v", err)
+		return
+	}
+
+	// users see the error in their history, so the full error is only logged
+	errText := sql.NullString{}
+	if translateErr != nil {
+		errText = sql.NullString{String: failureMessage(translateErr), Valid: true}
+		log.Printf("translation of request %v failed: %v", request.ID, translateErr)
+	}
+
+	_, err = cfg.DB.CreateLog(ctx, database.CreateLogParams{
+		IsSuccessful: translateErr == nil,
+		Cached:       cached,
+		Error:        errText,
+		RequestID:    request.ID,
+	})
+	if err != nil {
+		log.Printf("Error storing log for request %v: %v", request.ID, err)
+	}
+}
+
+// the error as stored for users to see. like errorRespond only the message of an
+// apperr.Error is kept, anything else may hold provider responses or internal details
+func failureMessage(err error) string {
+	var appErr *apperr.Error
+	if errors.As(err, &appErr) {
+		return appErr.Message
+	}
+	return "translation failed"
+}
+
+func logEntryFromRow(entry database.GetLogsRow) LogEntry {
+	result := LogEntry{
+		ID:           entry.ID,
+		CreatedAt:    entry.CreatedAt,
+		IsSuccessful: entry.IsSuccessful,
+		Cached:       entry.Cached,
+		Error:        entry.Error.String,
+		RequestID:    entry.RequestID,
+		Provider:     entry.Provider,
+		ReqType:      entry.ReqType,
+		SourceLang:   entry.FromLang,
+		TargetLang:   entry.ToLang,
+		Email:        entry.Email.String,
+	}
+	if entry.UserID.Valid {
+		result.UserID = &entry.UserID.UUID
+	}
+	if entry.OrganizationID.Valid {
+		result.OrganizationID = &entry.OrganizationID.UUID
+	}
+	return result
+}
+
+// reads the optional log filters from the query, unset filters match everything
+func getLogFilters(r *http.Request) (database.GetLogsParams, error) {
+	query := r.URL.Query()
+	params := database.GetLogsParams{}
+
+	if v := query.Get("user_id"); v != "" {
+		userUUID, err := uuid.Parse(v)
+		if err != nil {
+			return params, apperr.ErrInvalidRequest.WithMessage("invalid user_id")
+		}
+		params.UserID = uuid.NullUUID{UUID: userUUID, Valid: true}
+	}
+	if v := query.Get("organization_id"); v != "" {
+		orgUUID, err := uuid.Parse(v)
+		if err != nil {
+			return params, apperr.ErrInvalidRequest.WithMessage("invalid organization_id")
+		}
+		params.OrganizationID = uuid.NullUUID{UUID: orgUUID, Valid: true}
+	}
+	if v := query.Get("provider"); v != "" {
+		params.Provider = sql.NullString{String: v, Valid: true}
+	}
+	if v := query.Get("source_lang"); v != "" {
+		params.FromLang = sql.NullString{String: v, Valid: true}
+	}
+	if v := query.Get("target_lang"); v != "" {
+		params.ToLang = sql.NullString{String: v, Valid: true}
+	}
+	if v := query.Get("since"); v != "" {
+		since, _, err := parseLogTime(v)
+		if err != nil {
+			return params, apperr.ErrInvalidRequest.WithMessage("invalid since, use RFC3339 or YYYY-MM-DD")
+		}
+		params.CreatedAfter = sql.NullTime{Time: since, Valid: true}
+	}
+	if v := query.Get("until"); v != "" {
+		until, dateOnly, err := parseLogTime(v)
+		if err != nil {
+			return params, apperr.ErrInvalidRequest.WithMessage("invalid until, use RFC3339 or YYYY-MM-DD")
+		}
+		// a plain date includes the whole day
+		if dateOnly {
+			until = until.Add(time.Hour * 24)
+		}
+		params.CreatedBefore = sql.NullTime{Time: until, Valid: true}
+	}
+	if v := query.Get("success"); v != "" {
+		success, err := strconv.ParseBool(v)
+		if err != nil {
+			return params, apperr.ErrInvalidRequest.WithMessage("invalid success, use true or false")
+		}
+		params.IsSuccessful = sql.NullBool{Bool: success, Valid: true}
+	}
+	if v := query.Get("cached"); v != "" {
+		cached, err := strconv.ParseBool(v)
+		if err != nil {
+			return params, apperr.ErrInvalidRequest.WithMessage("invalid cached, use true or false")
+		}
+		params.Cached = sql.NullBool{Bool: cached, Valid: true}
+	}
+
+	return params, nil
+}
+
+// timestamps are stored without a zone, so explicit offsets are normalized to UTC
+func parseLogTime(value string) (time.Time, bool, error) {
+	t, err := time.Parse(time.DateOnly, value)
+	if err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t.UTC(), false, nil
+}
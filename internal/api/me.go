@@ -0,0 +1,146 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/auth"
+	"github.com/o0n1x/mass-translate-server/internal/database"
+)
+
+// handles the self service endpoints every signed in user has under /api/me
+
+type Profile struct {
+	User
+	// what the credentials of the request can do, API keys only get the permissions of their scopes
+	Permissions    []string   `json:"permissions"`
+	OrganizationID *uuid.UUID `json:"organization_id"`
+	OrgAdmin       bool       `json:"org_admin"`
+}
+
+func (cfg *ApiConfig) GetMe(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(database.User)
+	permissions := r.Context().Value("permissions").([]string)
+
+	returnedUsers, err := cfg.usersResponse(r.Context(), []database.User{user})
+	if err != nil {
+		log.Printf("Error retrieving user roles: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve user"))
+		return
+	}
+	member, ok, err := cfg.membership(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error retrieving membership of user %v: %v", user.ID, err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve user"))
+		return
+	}
+
+	profile := Profile{
+		User:        returnedUsers[0],
+		Permissions: permissions,
+	}
+	if ok {
+		profile.OrganizationID = &member.OrganizationID
+		profile.OrgAdmin = member.IsAdmin
+	}
+
+	jsonRespond(w, 200, profile)
+}
+
+// every other session is ended like an admin password change would, the response is a new
+// session so the caller stays logged in
+func (cfg *ApiConfig) ChangeMyPassword(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(database.User)
+
+	type parameters struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		errorRespond(w, apperr.ErrInvalidJSON)
+		return
+	}
+	if params.NewPassword == "" {
+		errorRespond(w, apperr.ErrInvalidRequest.WithMessage("new_password is required"))
+		return
+	}
+
+	ok, err := auth.CheckPasswordHash(params.CurrentPassword, user.HashedPassword.String)
+	if !ok {
+		log.Printf("user %v gave a wrong current password: %v", user.ID, err)
+		errorRespond(w, apperr.ErrInvalidCredentials.WithMessage("Incorrect current password"))
+		return
+	}
+
+	hashedpass, err := auth.HashPassword(params.NewPassword)
+	if err != nil {
+		log.Printf("Error hashing password: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error changing password"))
+		return
+	}
+	err = cfg.DB.UpdatePassword(r.Context(), database.UpdatePasswordParams{
+		ID:             user.ID,
+		HashedPassword: sql.NullString{String: hashedpass, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error changing password of user %v: %v", user.ID, err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error changing password"))
+		return
+	}
+
+	err = cfg.revokeSessions(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error revoking sessions of user %v: %v", user.ID, err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error changing password"))
+		return
+	}
+	session, err := cfg.createSession(r.Context(), user)
+	if err != nil {
+		log.Printf("Error creating session: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to create token"))
+		return
+	}
+
+	jsonRespond(w, 200, session)
+}
+
+// the user's own translations, newest first. takes the same filters as the admin logs
+// except user_id
+func (cfg *ApiConfig) GetMyHistory(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(database.User)
+
+	params, err := getLogFilters(r)
+	if err != nil {
+		log.Printf("Error invalid history filter: %v", err)
+		errorRespond(w, err)
+		return
+	}
+	params.UserID = uuid.NullUUID{UUID: user.ID, Valid: true}
+
+	limit, offset := getPagination(r)
+	params.Limit = int32(limit)
+	params.Offset = int32(offset)
+
+	entries, err := cfg.DB.GetLogs(r.Context(), params)
+	if err != nil {
+		log.Printf("Error retrieving history: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve history"))
+		return
+	}
+
+	history := []LogEntry{}
+	for _, entry := range entries {
+		history = append(history, logEntryFromRow(entry))
+	}
+
+	jsonRespond(w, 200, history)
+}
@@ -0,0 +1,403 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/auth"
+	"github.com/o0n1x/mass-translate-server/internal/database"
+)
+
+// handles organizations, their members and org-wide quotas. users with orgs.admin manage
+// every organization on /api/admin/orgs, org admins manage their own one on /api/me/org
+
+type Organization struct {
+	ID               uuid.UUID `json:"id"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	Name             string    `json:"name"`
+	MonthlyCharLimit *int64    `json:"monthly_char_limit"`
+	MonthlyByteLimit *int64    `json:"monthly_byte_limit"`
+}
+
+type Member struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Email    string    `json:"email"`
+	Admin    bool      `json:"admin"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+func (cfg *ApiConfig) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		errorRespond(w, apperr.ErrInvalidJSON)
+		return
+	}
+	if params.Name == "" {
+		errorRespond(w, apperr.ErrInvalidRequest.WithMessage("name is required"))
+		return
+	}
+
+	org, err := cfg.DB.CreateOrganization(r.Context(), params.Name)
+	if err != nil {
+		log.Printf("Error creating organization: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to create organization"))
+		return
+	}
+
+	jsonRespond(w, 201, organizationResponse(org))
+}
+
+func (cfg *ApiConfig) GetOrganizations(w http.ResponseWriter, r *http.Request) {
+	limit, offset := getPagination(r)
+
+	orgs, err := cfg.DB.GetOrganizations(r.Context(), database.GetOrganizationsParams{
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	})
+	if err != nil {
+		log.Printf("Error retrieving organizations: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve organizations"))
+		return
+	}
+
+	returnedOrgs := []Organization{}
+	for _, org := range orgs {
+		returnedOrgs = append(returnedOrgs, organizationResponse(org))
+	}
+
+	jsonRespond(w, 200, returnedOrgs)
+}
+
+// members can see their organization, org admins can see and manage its members and usage
+func (cfg *ApiConfig) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	org, ok := cfg.orgFromRequest(w, r, false)
+	if !ok {
+		return
+	}
+	jsonRespond(w, 200, organizationResponse(org))
+}
+
+// members are removed with the organization, their requests and glossaries are kept
+func (cfg *ApiConfig) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	org, ok := cfg.orgFromRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	err := cfg.DB.DeleteOrganization(r.Context(), org.ID)
+	if err != nil {
+		log.Printf("Error deleting organization: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error deleting organization"))
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *ApiConfig) GetOrganizationMembers(w http.ResponseWriter, r *http.Request) {
+	org, ok := cfg.orgFromRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	members, err := cfg.DB.GetOrganizationMembers(r.Context(), org.ID)
+	if err != nil {
+		log.Printf("Error retrieving organization members: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve members"))
+		return
+	}
+
+	returnedMembers := []Member{}
+	for _, member := range members {
+		returnedMembers = append(returnedMembers, Member{
+			UserID:   member.UserID,
+			Email:    member.Email,
+			Admin:    member.IsAdmin,
+			JoinedAt: member.CreatedAt,
+		})
+	}
+
+	jsonRespond(w, 200, returnedMembers)
+}
+
+// org admins create new users straight into their organization. moving an existing user
+// in by user_id needs orgs.admin, otherwise any org admin could claim any account
+func (cfg *ApiConfig) AddOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	org, ok := cfg.orgFromRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		UserID   *uuid.UUID `json:"user_id,omitempty"`
+		Email    string     `json:"email"`
+		Password string     `json:"password"`
+		Admin    bool       `json:"admin"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		errorRespond(w, apperr.ErrInvalidJSON)
+		return
+	}
+
+	// a new user is only kept once they are a member, or a retry would find their email taken
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to add member"))
+		return
+	}
+	defer tx.Rollback()
+	db := cfg.DB.WithTx(tx)
+
+	var user database.User
+	if params.UserID != nil {
+		if !hasPermission(r, auth.PermOrgsAdmin) {
+			errorRespond(w, apperr.ErrForbidden.WithMessage("adding existing users needs the orgs.admin permission"))
+			return
+		}
+		user, err = cfg.DB.GetUser(r.Context(), *params.UserID)
+		if err != nil {
+			log.Printf("Error retrieving user: %v", err)
+			errorRespond(w, apperr.ErrUserNotFound)
+			return
+		}
+		_, inOrg, err := cfg.membership(r.Context(), user.ID)
+		if err != nil {
+			log.Printf("Error retrieving membership of user %v: %v", user.ID, err)
+			errorRespond(w, apperr.ErrInternal.WithMessage("Failed to add member"))
+			return
+		}
+		if inOrg {
+			errorRespond(w, apperr.ErrAlreadyMember)
+			return
+		}
+	} else {
+		if params.Email == "" || params.Password == "" {
+			errorRespond(w, apperr.ErrInvalidRequest.WithMessage("email and password, or user_id, are required"))
+			return
+		}
+		user, err = cfg.createUser(r.Context(), db, params.Email, params.Password, []string{auth.RoleTranslator})
+		if err != nil {
+			log.Printf("Error creating user: %v", err)
+			errorRespond(w, apperr.ErrInternal.WithMessage("User Registeration Failed"))
+			return
+		}
+	}
+
+	member, err := db.AddOrganizationMember(r.Context(), database.AddOrganizationMemberParams{
+		UserID:         user.ID,
+		IsAdmin:        params.Admin,
+		OrganizationID: org.ID,
+	})
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("Error adding user %v to organization %v: %v", user.ID, org.ID, err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to add member"))
+		return
+	}
+
+	jsonRespond(w, 201, Member{
+		UserID:   member.UserID,
+		Email:    user.Email,
+		Admin:    member.IsAdmin,
+		JoinedAt: member.CreatedAt,
+	})
+}
+
+func (cfg *ApiConfig) UpdateOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	org, ok := cfg.orgFromRequest(w, r, true)
+	if !ok {
+		return
+	}
+	user, ok := cfg.memberFromPath(w, r, org.ID)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		Admin bool `json:"admin"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		errorRespond(w, apperr.ErrInvalidJSON)
+		return
+	}
+
+	member, err := cfg.DB.SetOrganizationMemberAdmin(r.Context(), database.SetOrganizationMemberAdminParams{
+		OrganizationID: org.ID,
+		UserID:         user.ID,
+		IsAdmin:        params.Admin,
+	})
+	if err != nil {
+		log.Printf("Error updating member %v of organization %v: %v", user.ID, org.ID, err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error updating member"))
+		return
+	}
+
+	jsonRespond(w, 200, Member{
+		UserID:   member.UserID,
+		Email:    user.Email,
+		Admin:    member.IsAdmin,
+		JoinedAt: member.CreatedAt,
+	})
+}
+
+// removes the user from the organization, the account itself stays
+func (cfg *ApiConfig) RemoveOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	org, ok := cfg.orgFromRequest(w, r, true)
+	if !ok {
+		return
+	}
+	user, ok := cfg.memberFromPath(w, r, org.ID)
+	if !ok {
+		return
+	}
+
+	err := cfg.DB.RemoveOrganizationMember(r.Context(), database.RemoveOrganizationMemberParams{
+		OrganizationID: org.ID,
+		UserID:         user.ID,
+	})
+	if err != nil {
+		log.Printf("Error removing member %v of organization %v: %v", user.ID, org.ID, err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error removing member"))
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// resolves the organization the request is about: the {id} path value on the admin routes,
+// the user's own organization on /api/me/org. members that are not org admins get a 403
+// when admin is set
+func (cfg *ApiConfig) orgFromRequest(w http.ResponseWriter, r *http.Request, admin bool) (database.Organization, bool) {
+	if r.PathValue("id") == "" {
+		return cfg.ownOrg(w, r, admin)
+	}
+
+	orgUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid organization ID: %v", err)
+		errorRespond(w, apperr.ErrInvalidID)
+		return database.Organization{}, false
+	}
+
+	org, err := cfg.DB.GetOrganization(r.Context(), orgUUID)
+	if err != nil {
+		log.Printf("Error retrieving organization: %v", err)
+		errorRespond(w, apperr.ErrOrgNotFound)
+		return database.Organization{}, false
+	}
+	return org, true
+}
+
+func (cfg *ApiConfig) ownOrg(w http.ResponseWriter, r *http.Request, admin bool) (database.Organization, bool) {
+	user := r.Context().Value("user").(database.User)
+	member, ok, err := cfg.membership(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error retrieving membership of user %v: %v", user.ID, err)
+		errorRespond(w, apperr.ErrInternal)
+		return database.Organization{}, false
+	}
+	if !ok {
+		errorRespond(w, apperr.ErrOrgNotFound.WithMessage("user is not in an organization"))
+		return database.Organization{}, false
+	}
+	if admin && !member.IsAdmin {
+		log.Printf("user %v attempted to manage organization %v", user.ID, member.OrganizationID)
+		errorRespond(w, apperr.ErrForbidden.WithMessage("only org admins can manage the organization"))
+		return database.Organization{}, false
+	}
+
+	org, err := cfg.DB.GetOrganization(r.Context(), member.OrganizationID)
+	if err != nil {
+		log.Printf("Error retrieving organization: %v", err)
+		errorRespond(w, apperr.ErrOrgNotFound)
+		return database.Organization{}, false
+	}
+	return org, true
+}
+
+// resolves the {user_id} path value to a member of the organization that the caller may
+// manage, users with admin permissions the caller lacks are off limits
+func (cfg *ApiConfig) memberFromPath(w http.ResponseWriter, r *http.Request, orgID uuid.UUID) (database.User, bool) {
+	userUUID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		log.Printf("Error invalid user ID: %v", err)
+		errorRespond(w, apperr.ErrInvalidID)
+		return database.User{}, false
+	}
+
+	member, ok, err := cfg.membership(r.Context(), userUUID)
+	if err != nil {
+		log.Printf("Error retrieving membership of user %v: %v", userUUID, err)
+		errorRespond(w, apperr.ErrInternal)
+		return database.User{}, false
+	}
+	if !ok || member.OrganizationID != orgID {
+		errorRespond(w, apperr.ErrMemberNotFound)
+		return database.User{}, false
+	}
+
+	err = cfg.checkCanManageUser(r, userUUID)
+	if err != nil {
+		errorRespond(w, err)
+		return database.User{}, false
+	}
+
+	user, err := cfg.DB.GetUser(r.Context(), userUUID)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		errorRespond(w, apperr.ErrUserNotFound)
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// the organization the user belongs to, if any
+func (cfg *ApiConfig) membership(ctx context.Context, userID uuid.UUID) (database.OrganizationMember, bool, error) {
+	member, err := cfg.DB.GetMembership(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.OrganizationMember{}, false, nil
+	}
+	if err != nil {
+		return database.OrganizationMember{}, false, err
+	}
+	return member, true, nil
+}
+
+func organizationResponse(org database.Organization) Organization {
+	return Organization{
+		ID:               org.ID,
+		CreatedAt:        org.CreatedAt,
+		UpdatedAt:        org.UpdatedAt,
+		Name:             org.Name,
+		MonthlyCharLimit: fromNullInt64(org.MonthlyCharLimit),
+		MonthlyByteLimit: fromNullInt64(org.MonthlyByteLimit),
+	}
+}
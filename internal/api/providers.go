@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/o0n1x/mass-translate-package/format"
+	"github.com/o0n1x/mass-translate-package/provider"
+	"github.com/o0n1x/mass-translate-package/provider/deepl"
+	"github.com/o0n1x/mass-translate-package/translator"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/breaker"
+	"github.com/o0n1x/mass-translate-server/internal/formats"
+	"github.com/o0n1x/mass-translate-server/internal/glossary"
+	"github.com/o0n1x/mass-translate-server/internal/metrics"
+	"github.com/o0n1x/mass-translate-server/internal/provider/deeplglossary"
+)
+
+// handles the registry of enabled translation providers
+
+// consecutive failures before a provider is taken out of rotation, and for how long
+const breakerThreshold = 5
+const breakerCooldown = time.Second * 30
+
+// text translations that take longer than this are treated as a provider failure.
+// documents are not limited here since they legitimately take minutes
+const providerTimeout = time.Second * 30
+
+type ProviderInfo struct {
+	Name       provider.Provider `json:"name"`
+	Path       string            `json:"path"`
+	Version    string            `json:"version"`
+	Glossaries bool              `json:"glossaries"`
+}
+
+type ProviderStatus struct {
+	Name     provider.Provider `json:"name"`
+	Fallback int               `json:"fallback_position,omitempty"`
+	Breaker  breaker.Status    `json:"breaker"`
+}
+
+// creates a client for the provider and makes it available under /api/{provider}/...
+// providers are looked up in the mass-translate-package registry, so any package that
+// registers itself there can be enabled. extensions narrow the file types it accepts down
+// from what it supports, without any it accepts every type it supports
+func (cfg *ApiConfig) EnableProvider(name provider.Provider, apiKey string, extensions []string) error {
+	client, err := provider.GetClient(name, apiKey)
+	if err != nil {
+		return err
+	}
+	if cfg.Providers == nil {
+		cfg.Providers = map[provider.Provider]provider.Client{}
+		cfg.Breakers = map[provider.Provider]*breaker.Breaker{}
+		cfg.AllowedExtensions = map[provider.Provider]map[string]bool{}
+	}
+	cfg.Providers[name] = client
+	cfg.Breakers[name] = breaker.New(breakerThreshold, breakerCooldown)
+
+	supported := formats.Supported(name)
+	if len(extensions) == 0 {
+		extensions = supported
+	}
+	cfg.AllowedExtensions[name] = map[string]bool{}
+	for _, ext := range extensions {
+		ext = strings.ToLower(ext)
+		if !slices.Contains(supported, ext) {
+			return fmt.Errorf("%s does not support %s files, it supports %s", name, ext, strings.Join(supported, " "))
+		}
+		cfg.AllowedExtensions[name][ext] = true
+	}
+
+	if cfg.GlossaryClients == nil {
+		cfg.GlossaryClients = map[provider.Provider]glossary.Translator{}
+	}
+	switch c := client.(type) {
+	case glossary.Translator:
+		cfg.GlossaryClients[name] = c
+	case *deepl.DeepLClient:
+		cfg.GlossaryClients[name] = deeplglossary.New(c, cfg.DB)
+	}
+	return nil
+}
+
+// sets the ordered list of providers tried when the requested one fails.
+// every provider in the chain has to be enabled first
+func (cfg *ApiConfig) SetFallback(names []string) error {
+	fallback := []provider.Provider{}
+	for _, name := range names {
+		client, ok := cfg.GetProvider(name)
+		if !ok {
+			return fmt.Errorf("fallback provider %s is not enabled", name)
+		}
+		fallback = append(fallback, client.Name())
+	}
+	cfg.Fallback = fallback
+	return nil
+}
+
+// looks up a provider by its path name, names are matched case insensitively so
+// DeepL is served on /api/deepl
+func (cfg *ApiConfig) GetProvider(name string) (provider.Client, bool) {
+	for providerName, client := range cfg.Providers {
+		if strings.EqualFold(string(providerName), name) {
+			return client, true
+		}
+	}
+	return nil, false
+}
+
+func (cfg *ApiConfig) GetProviders(w http.ResponseWriter, r *http.Request) {
+	providers := []ProviderInfo{}
+	for name, client := range cfg.Providers {
+		_, glossaries := cfg.GlossaryClients[name]
+		providers = append(providers, ProviderInfo{
+			Name:       name,
+			Path:       providerPath(name),
+			Version:    client.Version(),
+			Glossaries: glossaries,
+		})
+	}
+	slices.SortFunc(providers, func(a, b ProviderInfo) int {
+		return strings.Compare(string(a.Name), string(b.Name))
+	})
+
+	jsonRespond(w, 200, providers)
+}
+
+func (cfg *ApiConfig) GetProviderStatus(w http.ResponseWriter, r *http.Request) {
+	providers := []ProviderStatus{}
+	for name := range cfg.Providers {
+		providers = append(providers, ProviderStatus{
+			Name:     name,
+			Fallback: slices.Index(cfg.Fallback, name) + 1,
+			Breaker:  cfg.Breakers[name].Status(),
+		})
+	}
+	slices.SortFunc(providers, func(a, b ProviderStatus) int {
+		return strings.Compare(string(a.Name), string(b.Name))
+	})
+
+	jsonRespond(w, 200, providers)
+}
+
+// resolves the {provider} path value, responding with 404 if it is unknown or disabled
+func (cfg *ApiConfig) providerFromRequest(w http.ResponseWriter, r *http.Request) (provider.Client, bool) {
+	client, ok := cfg.GetProvider(r.PathValue("provider"))
+	if !ok {
+		log.Printf("Error unknown or disabled provider: %s", r.PathValue("provider"))
+		errorRespond(w, apperr.ErrProviderNotFound)
+		return nil, false
+	}
+	return client, true
+}
+
+// tries the requested provider and then the fallback chain in order, skipping providers
+// whose breaker is open. returns the client that served the request so callers can cache
+// and record under the right provider. errors caused by the request itself are returned
+// straight away since another provider would reject it too. with a glossary only
+// providers that support glossaries are tried
+func (cfg *ApiConfig) translateWithFallback(ctx context.Context, client provider.Client, req provider.Request, g *glossary.Glossary) (provider.Response, provider.Client, error) {
+	var lastErr error = apperr.ErrNoProvider
+	for _, candidate := range cfg.providerChain(client) {
+		if req.ReqType == format.File && !cfg.isFileAllowed(candidate.Name(), req.FileName) {
+			continue
+		}
+		if _, ok := cfg.GlossaryClients[candidate.Name()]; g != nil && !ok {
+			continue
+		}
+		b := cfg.Breakers[candidate.Name()]
+		if !b.Allow() {
+			log.Printf("provider %s skipped: circuit open", candidate.Name())
+			continue
+		}
+
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if req.ReqType == format.Text {
+			attemptCtx, cancel = context.WithTimeout(ctx, providerTimeout)
+		}
+		res, err := cfg.translateWith(attemptCtx, candidate, req, g)
+		cancel()
+
+		switch {
+		case err == nil:
+			b.Success()
+			return res, candidate, nil
+		case isClientError(err):
+			// the provider answered, the request was wrong
+			b.Success()
+			return provider.Response{}, candidate, err
+		case ctx.Err() != nil:
+			// the caller went away, that says nothing about the provider
+			b.Abort()
+			return provider.Response{}, candidate, err
+		default:
+			log.Printf("provider %s failed, trying next: %v", candidate.Name(), err)
+			b.Failure(err)
+			lastErr = err
+		}
+	}
+	return provider.Response{}, client, lastErr
+}
+
+// the requested provider first, then the configured fallbacks without duplicates
+func (cfg *ApiConfig) providerChain(client provider.Client) []provider.Client {
+	chain := []provider.Client{client}
+	for _, name := range cfg.Fallback {
+		if name == client.Name() {
+			continue
+		}
+		if fallback, ok := cfg.Providers[name]; ok {
+			chain = append(chain, fallback)
+		}
+	}
+	return chain
+}
+
+// every call that reaches a provider goes through here so usage and latency are measured in one place
+func (cfg *ApiConfig) translateWith(ctx context.Context, client provider.Client, req provider.Request, g *glossary.Glossary) (provider.Response, error) {
+	name := string(client.Name())
+	metrics.AddProviderUsage(name, client.GetCharCount(req), len(req.Binary))
+
+	start := time.Now()
+	var res provider.Response
+	var err error
+	switch {
+	case g != nil:
+		res, err = cfg.GlossaryClients[client.Name()].TranslateWithGlossary(ctx, req, *g)
+	case client.Name() == provider.DeepL:
+		// DeepL documents are async on their side, the translator waits for them
+		res, err = translator.Translate(ctx, req, client)
+	default:
+		res, err = client.Translate(ctx, req)
+	}
+	metrics.ObserveTranslation(name, req.ReqType.String(), start, err)
+	return res, providerError(err)
+}
+
+func (cfg *ApiConfig) isFileAllowed(clienttype provider.Provider, filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	return cfg.AllowedExtensions[clienttype][ext]
+}
+
+func providerPath(name provider.Provider) string {
+	return "/api/" + strings.ToLower(string(name))
+}
+
+// errors caused by the request rather than the provider
+func isClientError(err error) bool {
+	var appErr *apperr.Error
+	return errors.As(err, &appErr) && appErr.Status < http.StatusInternalServerError
+}
+
+// types errors coming back from a provider. our own providers return apperr errors already,
+// mass-translate-package only has plain error strings so this is the one place that relies
+// on their wording until its error package lands
+func providerError(err error) error {
+	var appErr *apperr.Error
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &appErr):
+		return err
+	case strings.Contains(err.Error(), "Invalid Source Language"):
+		return fmt.Errorf("%w: %v", apperr.ErrInvalidSourceLang, err)
+	case strings.Contains(err.Error(), "Invalid Target Language"):
+		return fmt.Errorf("%w: %v", apperr.ErrInvalidTargetLang, err)
+	default:
+		return fmt.Errorf("%w: %v", apperr.ErrTranslationFailed, err)
+	}
+}
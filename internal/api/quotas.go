@@ -0,0 +1,353 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-package/provider"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/database"
+)
+
+// handles per user and per organization monthly quotas and usage
+
+type Usage struct {
+	UserID           uuid.UUID `json:"user_id"`
+	PeriodStart      time.Time `json:"period_start"`
+	CharsUsed        int64     `json:"chars_used"`
+	MonthlyCharLimit *int64    `json:"monthly_char_limit"`
+	BytesUsed        int64     `json:"bytes_used"`
+	MonthlyByteLimit *int64    `json:"monthly_byte_limit"`
+}
+
+func (cfg *ApiConfig) GetUsage(w http.ResponseWriter, r *http.Request) {
+	userUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid user ID: %v", err)
+		errorRespond(w, apperr.ErrInvalidID)
+		return
+	}
+
+	_, err = cfg.DB.GetUser(r.Context(), userUUID)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		errorRespond(w, apperr.ErrUserNotFound)
+		return
+	}
+
+	cfg.usageRespond(w, r, userUUID)
+}
+
+func (cfg *ApiConfig) GetMyUsage(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(database.User)
+	cfg.usageRespond(w, r, user.ID)
+}
+
+func (cfg *ApiConfig) SetQuota(w http.ResponseWriter, r *http.Request) {
+	userUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid user ID: %v", err)
+		errorRespond(w, apperr.ErrInvalidID)
+		return
+	}
+
+	_, err = cfg.DB.GetUser(r.Context(), userUUID)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		errorRespond(w, apperr.ErrUserNotFound)
+		return
+	}
+
+	// a missing or null limit means unlimited
+	type parameters struct {
+		MonthlyCharLimit *int64 `json:"monthly_char_limit"`
+		MonthlyByteLimit *int64 `json:"monthly_byte_limit"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		errorRespond(w, apperr.ErrInvalidJSON)
+		return
+	}
+
+	if (params.MonthlyCharLimit != nil && *params.MonthlyCharLimit < 0) || (params.MonthlyByteLimit != nil && *params.MonthlyByteLimit < 0) {
+		errorRespond(w, apperr.ErrInvalidRequest.WithMessage("quota limits can not be negative"))
+		return
+	}
+
+	_, err = cfg.DB.SetQuota(r.Context(), database.SetQuotaParams{
+		UserID:           userUUID,
+		MonthlyCharLimit: toNullInt64(params.MonthlyCharLimit),
+		MonthlyByteLimit: toNullInt64(params.MonthlyByteLimit),
+	})
+	if err != nil {
+		log.Printf("Error setting quota: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error setting quota"))
+		return
+	}
+
+	cfg.usageRespond(w, r, userUUID)
+}
+
+type OrganizationUsage struct {
+	OrganizationID   uuid.UUID     `json:"organization_id"`
+	PeriodStart      time.Time     `json:"period_start"`
+	CharsUsed        int64         `json:"chars_used"`
+	MonthlyCharLimit *int64        `json:"monthly_char_limit"`
+	BytesUsed        int64         `json:"bytes_used"`
+	MonthlyByteLimit *int64        `json:"monthly_byte_limit"`
+	Members          []MemberUsage `json:"members"`
+}
+
+// usage per member, only members that translated something this period are listed
+type MemberUsage struct {
+	// null for the usage of members that were deleted since
+	UserID    *uuid.UUID `json:"user_id"`
+	Email     string     `json:"email"`
+	CharsUsed int64      `json:"chars_used"`
+	BytesUsed int64      `json:"bytes_used"`
+}
+
+func (cfg *ApiConfig) GetOrganizationUsage(w http.ResponseWriter, r *http.Request) {
+	org, ok := cfg.orgFromRequest(w, r, true)
+	if !ok {
+		return
+	}
+	cfg.orgUsageRespond(w, r, org)
+}
+
+// org admins can see but not change their quota
+func (cfg *ApiConfig) SetOrganizationQuota(w http.ResponseWriter, r *http.Request) {
+	org, ok := cfg.orgFromRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	// a missing or null limit means unlimited
+	type parameters struct {
+		MonthlyCharLimit *int64 `json:"monthly_char_limit"`
+		MonthlyByteLimit *int64 `json:"monthly_byte_limit"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		errorRespond(w, apperr.ErrInvalidJSON)
+		return
+	}
+
+	if (params.MonthlyCharLimit != nil && *params.MonthlyCharLimit < 0) || (params.MonthlyByteLimit != nil && *params.MonthlyByteLimit < 0) {
+		errorRespond(w, apperr.ErrInvalidRequest.WithMessage("quota limits can not be negative"))
+		return
+	}
+
+	org, err = cfg.DB.SetOrganizationQuota(r.Context(), database.SetOrganizationQuotaParams{
+		ID:               org.ID,
+		MonthlyCharLimit: toNullInt64(params.MonthlyCharLimit),
+		MonthlyByteLimit: toNullInt64(params.MonthlyByteLimit),
+	})
+	if err != nil {
+		log.Printf("Error setting organization quota: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error setting quota"))
+		return
+	}
+
+	cfg.orgUsageRespond(w, r, org)
+}
+
+func (cfg *ApiConfig) usageRespond(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	quota, err := cfg.getQuota(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error retrieving quota: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve usage"))
+		return
+	}
+
+	periodStart := startOfMonth(time.Now())
+	usage, err := cfg.DB.GetUsage(r.Context(), database.GetUsageParams{
+		UserID: userID,
+		Since:  periodStart,
+	})
+	if err != nil {
+		log.Printf("Error retrieving usage: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve usage"))
+		return
+	}
+
+	jsonRespond(w, 200, Usage{
+		UserID:           userID,
+		PeriodStart:      periodStart,
+		CharsUsed:        usage.CharsUsed,
+		MonthlyCharLimit: fromNullInt64(quota.MonthlyCharLimit),
+		BytesUsed:        usage.BytesUsed,
+		MonthlyByteLimit: fromNullInt64(quota.MonthlyByteLimit),
+	})
+}
+
+func (cfg *ApiConfig) orgUsageRespond(w http.ResponseWriter, r *http.Request, org database.Organization) {
+	periodStart := startOfMonth(time.Now())
+	usage, err := cfg.DB.GetOrganizationUsage(r.Context(), database.GetOrganizationUsageParams{
+		OrganizationID: org.ID,
+		Since:          periodStart,
+	})
+	if err != nil {
+		log.Printf("Error retrieving organization usage: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve usage"))
+		return
+	}
+	rows, err := cfg.DB.GetOrganizationUsageByUser(r.Context(), database.GetOrganizationUsageByUserParams{
+		OrganizationID: org.ID,
+		Since:          periodStart,
+	})
+	if err != nil {
+		log.Printf("Error retrieving organization usage: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve usage"))
+		return
+	}
+
+	members := []MemberUsage{}
+	for _, row := range rows {
+		member := MemberUsage{
+			Email:     row.Email.String,
+			CharsUsed: row.CharsUsed,
+			BytesUsed: row.BytesUsed,
+		}
+		if row.UserID.Valid {
+			member.UserID = &row.UserID.UUID
+		}
+		members = append(members, member)
+	}
+
+	jsonRespond(w, 200, OrganizationUsage{
+		OrganizationID:   org.ID,
+		PeriodStart:      periodStart,
+		CharsUsed:        usage.CharsUsed,
+		MonthlyCharLimit: fromNullInt64(org.MonthlyCharLimit),
+		BytesUsed:        usage.BytesUsed,
+		MonthlyByteLimit: fromNullInt64(org.MonthlyByteLimit),
+		Members:          members,
+	})
+}
+
+// returns apperr.ErrQuotaExceeded if the user or their organization has used up their quota
+// or the request would go over it. users without a quota row are unlimited
+func (cfg *ApiConfig) checkQuota(ctx context.Context, userID uuid.UUID, chars int64, bytes int64) error {
+	err := cfg.checkUserQuota(ctx, userID, chars, bytes)
+	if err != nil {
+		return err
+	}
+	return cfg.checkOrgQuota(ctx, userID, chars, bytes)
+}
+
+func (cfg *ApiConfig) checkUserQuota(ctx context.Context, userID uuid.UUID, chars int64, bytes int64) error {
+	quota, err := cfg.getQuota(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !quota.MonthlyCharLimit.Valid && !quota.MonthlyByteLimit.Valid {
+		return nil
+	}
+
+	usage, err := cfg.DB.GetUsage(ctx, database.GetUsageParams{
+		UserID: userID,
+		Since:  startOfMonth(time.Now()),
+	})
+	if err != nil {
+		return err
+	}
+
+	if overLimit(quota.MonthlyCharLimit, usage.CharsUsed, chars) {
+		return apperr.ErrQuotaExceeded.WithMessagef("monthly character quota of %d used up (%d used)", quota.MonthlyCharLimit.Int64, usage.CharsUsed)
+	}
+	if overLimit(quota.MonthlyByteLimit, usage.BytesUsed, bytes) {
+		return apperr.ErrQuotaExceeded.WithMessagef("monthly file quota of %d bytes used up (%d used)", quota.MonthlyByteLimit.Int64, usage.BytesUsed)
+	}
+	return nil
+}
+
+// the quota of the organization is shared by all of its members
+func (cfg *ApiConfig) checkOrgQuota(ctx context.Context, userID uuid.UUID, chars int64, bytes int64) error {
+	member, ok, err := cfg.membership(ctx, userID)
+	if err != nil || !ok {
+		return err
+	}
+	org, err := cfg.DB.GetOrganization(ctx, member.OrganizationID)
+	if err != nil {
+		return err
+	}
+	if !org.MonthlyCharLimit.Valid && !org.MonthlyByteLimit.Valid {
+		return nil
+	}
+
+	usage, err := cfg.DB.GetOrganizationUsage(ctx, database.GetOrganizationUsageParams{
+		OrganizationID: org.ID,
+		Since:          startOfMonth(time.Now()),
+	})
+	if err != nil {
+		return err
+	}
+
+	if overLimit(org.MonthlyCharLimit, usage.CharsUsed, chars) {
+		return apperr.ErrQuotaExceeded.WithMessagef("organization's monthly character quota of %d used up (%d used)", org.MonthlyCharLimit.Int64, usage.CharsUsed)
+	}
+	if overLimit(org.MonthlyByteLimit, usage.BytesUsed, bytes) {
+		return apperr.ErrQuotaExceeded.WithMessagef("organization's monthly file quota of %d bytes used up (%d used)", org.MonthlyByteLimit.Int64, usage.BytesUsed)
+	}
+	return nil
+}
+
+func (cfg *ApiConfig) getQuota(ctx context.Context, userID uuid.UUID) (database.Quota, error) {
+	quota, err := cfg.DB.GetQuota(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Quota{UserID: userID}, nil
+	}
+	return quota, err
+}
+
+func overLimit(limit sql.NullInt64, used int64, requested int64) bool {
+	if !limit.Valid {
+		return false
+	}
+	return used >= limit.Int64 || used+requested > limit.Int64
+}
+
+// how much of the quota a request uses if it reaches the provider
+func requestUsage(req provider.Request) (int64, int64) {
+	var chars int64
+	for _, s := range req.Text {
+		chars += int64(utf8.RuneCountInString(s))
+	}
+	return chars, int64(len(req.Binary))
+}
+
+// quotas reset on the first of every month in UTC
+func startOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func toNullInt64(v *int64) sql.NullInt64 {
+	if v == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *v, Valid: true}
+}
+
+func fromNullInt64(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int64
+}
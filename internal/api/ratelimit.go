@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/database"
+	"github.com/o0n1x/mass-translate-server/internal/ratelimit"
+)
+
+// handles request rate limits. every request counts against its client IP and signed in
+// requests also against their user, whether they use a token or an API key. limits are
+// requests per minute, a user's own limit wins over the highest limit of their roles and
+// RateLimit applies when neither is set
+
+const rateLimitWindow = time.Minute
+
+type RateLimit struct {
+	UserID uuid.UUID `json:"user_id"`
+	// the user's own limit, null when their roles decide
+	RequestsPerMinute *int64 `json:"requests_per_minute"`
+	// what the user actually gets
+	EffectiveRequestsPerMinute int64 `json:"effective_requests_per_minute"`
+}
+
+// wraps the whole mux so anonymous routes like login are limited too. behind a proxy the
+// client IP comes from X-Forwarded-For when the proxy is in TrustedProxies, an IPRateLimit
+// of zero turns the IP limit off
+func (cfg *ApiConfig) MiddlewareRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.IPRateLimit > 0 && !cfg.allowRequest(w, r, "ip:"+cfg.clientIP(r), cfg.IPRateLimit) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// the user's headers replace the IP's since the user limit is usually the tighter one
+func (cfg *ApiConfig) allowUser(w http.ResponseWriter, r *http.Request, user database.User) bool {
+	limit, err := cfg.userRateLimit(r.Context(), user)
+	if err != nil {
+		// like the limiter itself, a failed lookup lets the request through
+		log.Printf("Error getting rate limit of user %v: %v", user.ID, err)
+		return true
+	}
+	return cfg.allowRequest(w, r, "user:"+user.ID.String(), limit)
+}
+
+// writes the headers and the 429. if redis is down requests go through unlimited
+func (cfg *ApiConfig) allowRequest(w http.ResponseWriter, r *http.Request, key string, requests int64) bool {
+	result, err := cfg.RateLimiter.Allow(r.Context(), key, ratelimit.Limit{Requests: requests, Window: rateLimitWindow})
+	if err != nil {
+		log.Printf("Error checking rate limit of %s: %v", key, err)
+		return true
+	}
+	result.WriteHeaders(w.Header())
+	if !result.Allowed {
+		log.Printf("rate limit of %s exceeded", key)
+		errorRespond(w, apperr.ErrRateLimited)
+		return false
+	}
+	return true
+}
+
+func (cfg *ApiConfig) userRateLimit(ctx context.Context, user database.User) (int64, error) {
+	if user.RequestsPerMinute.Valid {
+		return int64(user.RequestsPerMinute.Int32), nil
+	}
+	roleLimit, err := cfg.DB.GetRoleRateLimit(ctx, user.ID)
+	if err != nil {
+		return 0, err
+	}
+	if roleLimit > 0 {
+		return int64(roleLimit), nil
+	}
+	return cfg.RateLimit, nil
+}
+
+func (cfg *ApiConfig) GetRateLimit(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.userFromPath(w, r)
+	if !ok {
+		return
+	}
+	cfg.rateLimitRespond(w, r, user)
+}
+
+func (cfg *ApiConfig) SetRateLimit(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.userFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// a missing or null limit hands the decision back to the user's roles
+	type parameters struct {
+		RequestsPerMinute *int64 `json:"requests_per_minute"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		errorRespond(w, apperr.ErrInvalidJSON)
+		return
+	}
+
+	limit := sql.NullInt32{}
+	if params.RequestsPerMinute != nil {
+		if *params.RequestsPerMinute <= 0 || *params.RequestsPerMinute > 1<<31-1 {
+			errorRespond(w, apperr.ErrInvalidRequest.WithMessage("requests_per_minute must be a positive number"))
+			return
+		}
+		limit = sql.NullInt32{Int32: int32(*params.RequestsPerMinute), Valid: true}
+	}
+
+	user, err = cfg.DB.SetUserRateLimit(r.Context(), database.SetUserRateLimitParams{
+		ID:                user.ID,
+		RequestsPerMinute: limit,
+	})
+	if err != nil {
+		log.Printf("Error setting rate limit of user %v: %v", user.ID, err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error setting rate limit"))
+		return
+	}
+
+	cfg.rateLimitRespond(w, r, user)
+}
+
+func (cfg *ApiConfig) rateLimitRespond(w http.ResponseWriter, r *http.Request, user database.User) {
+	effective, err := cfg.userRateLimit(r.Context(), user)
+	if err != nil {
+		log.Printf("Error getting rate limit of user %v: %v", user.ID, err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve rate limit"))
+		return
+	}
+
+	response := RateLimit{
+		UserID:                     user.ID,
+		EffectiveRequestsPerMinute: effective,
+	}
+	if user.RequestsPerMinute.Valid {
+		limit := int64(user.RequestsPerMinute.Int32)
+		response.RequestsPerMinute = &limit
+	}
+
+	jsonRespond(w, 200, response)
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/auth"
+	"github.com/o0n1x/mass-translate-server/internal/database"
+)
+
+// handles roles and the permissions they grant. roles live in the database, routes are
+// guarded per permission in main.go
+
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	// null when the role sets no rate limit
+	RequestsPerMinute *int64 `json:"requests_per_minute"`
+}
+
+func (cfg *ApiConfig) GetRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := cfg.DB.GetRoles(r.Context())
+	if err != nil {
+		log.Printf("Error retrieving roles: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve roles"))
+		return
+	}
+
+	returnedRoles := []Role{}
+	for _, role := range roles {
+		returned := Role{
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: role.Permissions,
+		}
+		if role.RequestsPerMinute.Valid {
+			limit := int64(role.RequestsPerMinute.Int32)
+			returned.RequestsPerMinute = &limit
+		}
+		returnedRoles = append(returnedRoles, returned)
+	}
+
+	jsonRespond(w, 200, returnedRoles)
+}
+
+// the user's permissions, limited to what the API key's scopes allow
+func (cfg *ApiConfig) userPermissions(ctx context.Context, creds credentials) ([]string, error) {
+	permissions, err := cfg.DB.GetUserPermissions(ctx, creds.user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(permissions, func(permission string) bool {
+		return !creds.allows(auth.PermissionScope(permission))
+	}), nil
+}
+
+// whether whoever made the request has the permission, set by the middleware
+func hasPermission(r *http.Request, permission string) bool {
+	permissions, _ := r.Context().Value("permissions").([]string)
+	return slices.Contains(permissions, permission)
+}
+
+// roles can only be handed out by someone who already has every permission they grant
+func (cfg *ApiConfig) checkAssignableRoles(r *http.Request, names []string) error {
+	roles, err := cfg.DB.GetRoles(r.Context())
+	if err != nil {
+		log.Printf("Error retrieving roles: %v", err)
+		return apperr.ErrInternal
+	}
+
+	for _, name := range names {
+		i := slices.IndexFunc(roles, func(role database.GetRolesRow) bool { return role.Name == name })
+		if i == -1 {
+			return apperr.ErrInvalidRequest.WithMessagef("unknown role %q", name)
+		}
+		for _, permission := range roles[i].Permissions {
+			if !hasPermission(r, permission) {
+				return apperr.ErrForbidden.WithMessagef("can not assign role %s without the %s permission", name, permission)
+			}
+		}
+	}
+	return nil
+}
+
+// users with admin permissions can only be changed by someone who has those permissions too,
+// so a user-admin can not lock out or take over a super-admin
+func (cfg *ApiConfig) checkCanManageUser(r *http.Request, userID uuid.UUID) error {
+	permissions, err := cfg.DB.GetUserPermissions(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error getting permissions of user %v: %v", userID, err)
+		return apperr.ErrInternal
+	}
+	for _, permission := range permissions {
+		if auth.PermissionScope(permission) == auth.ScopeAdmin && !hasPermission(r, permission) {
+			return apperr.ErrForbidden.WithMessagef("can not manage a user with the %s permission", permission)
+		}
+	}
+	return nil
+}
+
+func (cfg *ApiConfig) usersResponse(ctx context.Context, users []database.User) ([]User, error) {
+	ids := []uuid.UUID{}
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	rows, err := cfg.DB.GetUsersRoles(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	roles := map[uuid.UUID][]string{}
+	for _, row := range rows {
+		roles[row.UserID] = append(roles[row.UserID], row.Role)
+	}
+
+	returnedUsers := []User{}
+	for _, user := range users {
+		userRoles := roles[user.ID]
+		if userRoles == nil {
+			userRoles = []string{}
+		}
+		returnedUsers = append(returnedUsers, User{
+			ID:        user.ID,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+			Email:     user.Email,
+			Roles:     userRoles,
+		})
+	}
+	return returnedUsers, nil
+}
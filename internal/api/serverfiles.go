@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-package/provider"
+	"github.com/o0n1x/mass-translate-server/internal/formats"
+	"github.com/o0n1x/mass-translate-server/internal/glossary"
+	"github.com/o0n1x/mass-translate-server/internal/subtitles"
+)
+
+// handles files the server translates itself instead of sending them to the provider as
+// documents. their texts go through the text path one by one, so each is cached on its own
+// and a file with one new string only sends that string to the provider
+
+type serverFileResult struct {
+	segmentResult
+	file     []byte
+	filename string
+	// values left in the source language since the provider lost one of their placeholders
+	untranslated int
+}
+
+// the registry decides, so a type is translated on the server exactly when it is listed
+// for every provider. the Server types are those the l10n and subtitles packages parse
+func translatedOnServer(filename string) bool {
+	f, ok := formats.Lookup(filename)
+	return ok && f.Server
+}
+
+// opts only apply to subtitles
+func (cfg *ApiConfig) translateOnServer(ctx context.Context, userID uuid.UUID, client provider.Client, req provider.Request, file *upload, g *glossary.Glossary, opts subtitles.Options) (serverFileResult, error) {
+	if subtitles.Supports(req.FileName) {
+		return cfg.translateSubtitles(ctx, userID, client, req, file, g, opts)
+	}
+	return cfg.translateLocalization(ctx, userID, client, req, file, g)
+}
+
+// X-Untranslated counts the values left in the source language
+func (res serverFileResult) setHeaders(w http.ResponseWriter) {
+	res.segmentResult.setHeaders(w)
+	w.Header().Set("X-Untranslated", fmt.Sprint(res.untranslated))
+}
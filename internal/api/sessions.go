@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/auth"
+	"github.com/o0n1x/mass-translate-server/internal/database"
+)
+
+// handles login sessions. a session is a refresh token row, access tokens carry its ID
+// so revoking the session logs the user out everywhere it was used
+
+type Session struct {
+	ID           uuid.UUID `json:"id"`
+	Email        string    `json:"email"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+}
+
+// trades a refresh token for a new access token and refresh token. the old refresh
+// token is revoked so each one can only be used once
+func (cfg *ApiConfig) Refresh(w http.ResponseWriter, r *http.Request) {
+	refreshToken, ok := refreshTokenFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// revoked as it is read, a token used twice at once only refreshes once
+	session, err := cfg.DB.RevokeActiveRefreshToken(r.Context(), auth.HashToken(refreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error invalid refresh token: %v", err)
+		errorRespond(w, apperr.ErrUnauthorized.WithMessage("refresh token invalid, expired or revoked"))
+		return
+	}
+	if err != nil {
+		log.Printf("Error revoking refresh token: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to refresh token"))
+		return
+	}
+
+	user, err := cfg.DB.GetUser(r.Context(), session.UserID)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		errorRespond(w, apperr.ErrUnauthorized)
+		return
+	}
+
+	newSession, err := cfg.createSession(r.Context(), user)
+	if err != nil {
+		log.Printf("Error creating session: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to create token"))
+		return
+	}
+
+	jsonRespond(w, 200, newSession)
+}
+
+// revokes the session of the refresh token, its access tokens stop working right away
+func (cfg *ApiConfig) Logout(w http.ResponseWriter, r *http.Request) {
+	refreshToken, ok := refreshTokenFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.DB.RevokeActiveRefreshToken(r.Context(), auth.HashToken(refreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		// already logged out
+		w.WriteHeader(204)
+		return
+	}
+	if err != nil {
+		log.Printf("Error revoking refresh token: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to logout"))
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// starts a new session for the user
+func (cfg *ApiConfig) createSession(ctx context.Context, user database.User) (Session, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return Session{}, err
+	}
+
+	session, err := cfg.DB.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		TokenHash:  auth.HashToken(refreshToken),
+		TtlSeconds: cfg.RefreshTokenTTL.Seconds(),
+		UserID:     user.ID,
+	})
+	if err != nil {
+		return Session{}, err
+	}
+
+	token, err := auth.MakeJWT(user.ID, session.ID, cfg.SECRET_JWT, cfg.TokenTTL)
+	if err != nil {
+		return Session{}, err
+	}
+
+	return Session{
+		ID:           user.ID,
+		Email:        user.Email,
+		Token:        token,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// ends every session of the user, e.g. after a password change
+func (cfg *ApiConfig) revokeSessions(ctx context.Context, userID uuid.UUID) error {
+	return cfg.DB.RevokeUserRefreshTokens(ctx, userID)
+}
+
+func refreshTokenFromRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	type parameters struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		errorRespond(w, apperr.ErrInvalidJSON)
+		return "", false
+	}
+	if params.RefreshToken == "" {
+		errorRespond(w, apperr.ErrInvalidRequest.WithMessage("refresh_token is required"))
+		return "", false
+	}
+	return params.RefreshToken, true
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-package/format"
+	"github.com/o0n1x/mass-translate-package/provider"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/glossary"
+	"github.com/o0n1x/mass-translate-server/internal/subtitles"
+)
+
+// handles SRT and WebVTT subtitles, the cue text is translated with cue numbers and timings
+// kept as they are
+
+// reads max_line_length and output_format from the fields of an upload form
+func subtitleOptions(fields map[string]string) (subtitles.Options, error) {
+	opts := subtitles.Options{}
+	if fields["max_line_length"] != "" {
+		length, err := strconv.Atoi(fields["max_line_length"])
+		if err != nil || length < 0 {
+			return opts, apperr.ErrInvalidRequest.WithMessage("max_line_length must be 0 or more")
+		}
+		opts.MaxLineLength = length
+	}
+	switch subtitles.Format(strings.ToLower(fields["output_format"])) {
+	case "":
+	case subtitles.SRT:
+		opts.Format = subtitles.SRT
+	case subtitles.VTT:
+		opts.Format = subtitles.VTT
+	default:
+		return opts, apperr.ErrInvalidRequest.WithMessage("output_format must be srt or vtt")
+	}
+	return opts, nil
+}
+
+func (cfg *ApiConfig) translateSubtitles(ctx context.Context, userID uuid.UUID, client provider.Client, req provider.Request, file *upload, g *glossary.Glossary, opts subtitles.Options) (serverFileResult, error) {
+	result := serverFileResult{segmentResult: segmentResult{served: client}, filename: subtitles.OutputName(req.FileName, opts)}
+	data, err := file.bytes()
+	if err != nil {
+		log.Printf("Error reading file: %v", err)
+		return result, apperr.ErrInternal.WithMessage("failed to read file")
+	}
+	parsed, err := subtitles.Parse(req.FileName, data)
+	if err != nil {
+		return result, apperr.ErrInvalidRequest.WithMessagef("%s: %v", req.FileName, err)
+	}
+
+	// sentences over several cues are one text, segments go to the provider in batches
+	textReq := provider.Request{ReqType: format.Text, Text: parsed.Texts(), From: req.From, To: req.To}
+	if len(textReq.Text) > 0 {
+		result.segmentResult, err = cfg.translateSegments(ctx, userID, client, textReq, g)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	result.file, err = parsed.Build(result.translations, opts)
+	if err != nil {
+		log.Printf("Error rebuilding %s: %v", req.FileName, err)
+		return result, apperr.ErrInternal.WithMessage("failed to rebuild file")
+	}
+	return result, nil
+}
@@ -0,0 +1,436 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-package/lang"
+	"github.com/o0n1x/mass-translate-package/provider"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/database"
+	"github.com/o0n1x/mass-translate-server/internal/tmx"
+)
+
+// handles the translation memory, a store of translated segments that does not expire.
+// approved entries are served for exact matches without calling a provider and close
+// ones are offered as suggestions. machine translations are recorded as pending so an
+// admin can approve them, TMX files are imported and exported as a whole
+
+// entry statuses
+const (
+	TMPending  = "pending"
+	TMApproved = "approved"
+	TMRejected = "rejected"
+)
+
+// most suggestions returned per text
+const maxTMSuggestions = 3
+
+// entries written to the database at once during an import or read at once for an export
+const tmBatchSize = 1000
+
+type TMEntry struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	SourceLang string    `json:"source_lang"`
+	TargetLang string    `json:"target_lang"`
+	Source     string    `json:"source"`
+	Target     string    `json:"target"`
+	Origin     string    `json:"origin"`
+	Status     string    `json:"status"`
+}
+
+type TMSuggestion struct {
+	ID     uuid.UUID `json:"id"`
+	Source string    `json:"source"`
+	Target string    `json:"target"`
+	Score  float32   `json:"score"`
+}
+
+// lists entries newest first, filtered by language pair and status
+func (cfg *ApiConfig) GetTMEntries(w http.ResponseWriter, r *http.Request) {
+	params, err := tmFilter(r)
+	if err != nil {
+		errorRespond(w, err)
+		return
+	}
+	limit, offset := getPagination(r)
+	params.Limit = int32(limit)
+	params.Offset = int32(offset)
+
+	entries, err := cfg.DB.GetTMEntries(r.Context(), params)
+	if err != nil {
+		log.Printf("Error retrieving translation memory: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("Failed to retrieve translation memory"))
+		return
+	}
+
+	returnedEntries := []TMEntry{}
+	for _, entry := range entries {
+		returnedEntries = append(returnedEntries, tmEntryResponse(entry))
+	}
+
+	jsonRespond(w, 200, returnedEntries)
+}
+
+// approves or rejects an entry, the translation can be corrected at the same time
+func (cfg *ApiConfig) UpdateTMEntry(w http.ResponseWriter, r *http.Request) {
+	entryID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		errorRespond(w, apperr.ErrInvalidID)
+		return
+	}
+
+	type parameters struct {
+		Status string  `json:"status"`
+		Target *string `json:"target,omitempty"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		errorRespond(w, apperr.ErrInvalidJSON)
+		return
+	}
+	if !validTMStatus(params.Status) {
+		errorRespond(w, apperr.ErrInvalidRequest.WithMessage("status must be pending, approved or rejected"))
+		return
+	}
+
+	entry, err := cfg.DB.GetTMEntry(r.Context(), entryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		errorRespond(w, apperr.ErrTMEntryNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error retrieving translation memory entry: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error updating translation memory entry"))
+		return
+	}
+	if params.Target == nil {
+		params.Target = &entry.Target
+	}
+	if strings.TrimSpace(*params.Target) == "" {
+		errorRespond(w, apperr.ErrInvalidRequest.WithMessage("target can not be empty"))
+		return
+	}
+
+	updated, err := cfg.DB.UpdateTMEntry(r.Context(), database.UpdateTMEntryParams{
+		ID:     entry.ID,
+		Target: *params.Target,
+		Status: params.Status,
+	})
+	if err != nil {
+		log.Printf("Error updating translation memory entry: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error updating translation memory entry"))
+		return
+	}
+
+	jsonRespond(w, 200, tmEntryResponse(updated))
+}
+
+// reads a TMX file from the body. imported entries are approved unless ?status= says
+// otherwise and replace existing entries for the same source. units without text are skipped
+func (cfg *ApiConfig) ImportTMX(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = TMApproved
+	}
+	if !validTMStatus(status) {
+		errorRespond(w, apperr.ErrInvalidRequest.WithMessage("status must be pending, approved or rejected"))
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxFileSize)
+
+	var imported, skipped int64
+	batch := newTMBatch()
+	flush := func() error {
+		n, err := cfg.DB.ImportTMEntries(r.Context(), database.ImportTMEntriesParams{
+			SourceLangs: batch.sourceLangs,
+			TargetLangs: batch.targetLangs,
+			Sources:     batch.sources,
+			Targets:     batch.targets,
+			Status:      status,
+		})
+		imported += n
+		batch = newTMBatch()
+		return err
+	}
+
+	err := tmx.Read(r.Body, func(unit tmx.Unit) error {
+		if strings.TrimSpace(unit.Source) == "" || strings.TrimSpace(unit.Target) == "" {
+			skipped++
+			return nil
+		}
+		batch.add(unit)
+		if len(batch.sources) < tmBatchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil && len(batch.sources) > 0 {
+		err = flush()
+	}
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		errorRespond(w, formFileError(err))
+		return
+	case errors.Is(err, tmx.ErrInvalid):
+		errorRespond(w, apperr.ErrInvalidRequest.WithMessage(err.Error()))
+		return
+	case err != nil:
+		log.Printf("Error importing translation memory: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error importing translation memory"))
+		return
+	}
+	log.Printf("imported %d translation memory entries, skipped %d", imported, skipped)
+
+	jsonRespond(w, 200, struct {
+		Imported int64 `json:"imported"`
+		Skipped  int64 `json:"skipped"`
+	}{
+		Imported: imported,
+		Skipped:  skipped,
+	})
+}
+
+// streams the entries as a TMX file, filtered like GetTMEntries. only approved entries
+// unless ?status= says otherwise
+func (cfg *ApiConfig) ExportTMX(w http.ResponseWriter, r *http.Request) {
+	params, err := tmFilter(r)
+	if err != nil {
+		errorRespond(w, err)
+		return
+	}
+	if !params.Status.Valid {
+		params.Status = sql.NullString{String: TMApproved, Valid: true}
+	}
+	params.Limit = tmBatchSize
+
+	// the first page is read before anything is written so a failing query still gets an error response
+	entries, err := cfg.DB.GetTMEntries(r.Context(), params)
+	if err != nil {
+		log.Printf("Error exporting translation memory: %v", err)
+		errorRespond(w, apperr.ErrInternal.WithMessage("error exporting translation memory"))
+		return
+	}
+
+	srcLang := "*all*"
+	if params.SourceLang.Valid {
+		srcLang = params.SourceLang.String
+	}
+	w.Header().Set("Content-Type", "application/x-tmx+xml")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"translation-memory.tmx\"")
+	writer, err := tmx.NewWriter(w, srcLang)
+	for err == nil && len(entries) > 0 {
+		for _, entry := range entries {
+			err = writer.Write(tmx.Unit{
+				SourceLang: entry.SourceLang,
+				TargetLang: entry.TargetLang,
+				Source:     entry.Source,
+				Target:     entry.Target,
+			})
+			if err != nil {
+				break
+			}
+		}
+		if err != nil || len(entries) < tmBatchSize {
+			break
+		}
+		params.Offset += tmBatchSize
+		entries, err = cfg.DB.GetTMEntries(r.Context(), params)
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		// the status is already sent, the client sees a truncated file
+		log.Printf("Error exporting translation memory: %v", err)
+	}
+}
+
+// fills in approved exact matches for the texts that are not translated yet and marks them
+// as hits. returns how many texts it filled. the memory is keyed by source language, so
+// auto detected requests never match
+func (cfg *ApiConfig) matchTranslationMemory(ctx context.Context, req provider.Request, translations []string, hits []bool) int {
+	if !knownSourceLang(req) {
+		return 0
+	}
+	sources := []string{}
+	for i, text := range req.Text {
+		if !hits[i] {
+			sources = append(sources, text)
+		}
+	}
+	if len(sources) == 0 {
+		return 0
+	}
+
+	entries, err := cfg.DB.GetTMExactMatches(ctx, database.GetTMExactMatchesParams{
+		SourceLang: req.From.Upper(),
+		TargetLang: req.To.Upper(),
+		Sources:    sources,
+	})
+	if err != nil {
+		log.Printf("translation memory error: %v", err)
+		return 0
+	}
+	matches := map[string]string{}
+	for _, entry := range entries {
+		matches[entry.Source] = entry.Target
+	}
+
+	matched := 0
+	for i, text := range req.Text {
+		if target, ok := matches[text]; ok && !hits[i] {
+			translations[i], hits[i] = target, true
+			matched++
+		}
+	}
+	return matched
+}
+
+// approved entries at least TMThreshold similar to each text, the closest first. the
+// threshold is set for a transaction so the trigram index can be used
+func (cfg *ApiConfig) tmSuggestions(ctx context.Context, req provider.Request) map[string][]TMSuggestion {
+	suggestions := map[string][]TMSuggestion{}
+	if !knownSourceLang(req) {
+		return suggestions
+	}
+	tx, err := cfg.DBConn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		log.Printf("translation memory error: %v", err)
+		return suggestions
+	}
+	defer tx.Rollback()
+	db := cfg.DB.WithTx(tx)
+	err = db.SetTMThreshold(ctx, strconv.FormatFloat(float64(cfg.TMThreshold), 'f', -1, 32))
+	if err != nil {
+		log.Printf("translation memory error: %v", err)
+		return suggestions
+	}
+
+	for _, text := range req.Text {
+		if _, done := suggestions[text]; done {
+			continue
+		}
+		rows, err := db.GetTMFuzzyMatches(ctx, database.GetTMFuzzyMatchesParams{
+			Text:       text,
+			SourceLang: req.From.Upper(),
+			TargetLang: req.To.Upper(),
+			Limit:      maxTMSuggestions,
+		})
+		if err != nil {
+			log.Printf("translation memory error: %v", err)
+			return suggestions
+		}
+		suggestions[text] = []TMSuggestion{}
+		for _, row := range rows {
+			suggestions[text] = append(suggestions[text], TMSuggestion{
+				ID:     row.TmEntry.ID,
+				Source: row.TmEntry.Source,
+				Target: row.TmEntry.Target,
+				Score:  row.Score,
+			})
+		}
+	}
+	return suggestions
+}
+
+// stores machine translations as pending entries, existing entries are kept as they are
+func (cfg *ApiConfig) recordTranslationMemory(ctx context.Context, clienttype provider.Provider, req provider.Request, translations []string) {
+	if !cfg.TMRecord || !knownSourceLang(req) {
+		return
+	}
+	err := cfg.DB.RecordTMEntries(ctx, database.RecordTMEntriesParams{
+		SourceLang: req.From.Upper(),
+		TargetLang: req.To.Upper(),
+		Sources:    req.Text,
+		Targets:    translations,
+		Origin:     string(clienttype),
+	})
+	if err != nil {
+		log.Printf("translation memory error: %v", err)
+	}
+}
+
+// the memory is keyed by source language, so auto detected requests are left out. "auto"
+// counts as auto detect like it does for the cache
+func knownSourceLang(req provider.Request) bool {
+	return req.From != "" && req.From != lang.AutoDetect && !strings.EqualFold(req.From.String(), "auto")
+}
+
+func validTMStatus(status string) bool {
+	return status == TMPending || status == TMApproved || status == TMRejected
+}
+
+func tmFilter(r *http.Request) (database.GetTMEntriesParams, error) {
+	query := r.URL.Query()
+	params := database.GetTMEntriesParams{}
+	if sourceLang := query.Get("source_lang"); sourceLang != "" {
+		params.SourceLang = sql.NullString{String: strings.ToUpper(sourceLang), Valid: true}
+	}
+	if targetLang := query.Get("target_lang"); targetLang != "" {
+		params.TargetLang = sql.NullString{String: strings.ToUpper(targetLang), Valid: true}
+	}
+	if status := query.Get("status"); status != "" {
+		if !validTMStatus(status) {
+			return params, apperr.ErrInvalidRequest.WithMessage("status must be pending, approved or rejected")
+		}
+		params.Status = sql.NullString{String: status, Valid: true}
+	}
+	return params, nil
+}
+
+// units waiting to be imported. a source appears once per language pair since one
+// statement can not update the same row twice, the last translation wins
+type tmBatch struct {
+	positions   map[tmx.Unit]int
+	sourceLangs []string
+	targetLangs []string
+	sources     []string
+	targets     []string
+}
+
+func newTMBatch() *tmBatch {
+	return &tmBatch{positions: map[tmx.Unit]int{}}
+}
+
+func (b *tmBatch) add(unit tmx.Unit) {
+	key := tmx.Unit{SourceLang: unit.SourceLang, TargetLang: unit.TargetLang, Source: unit.Source}
+	if i, ok := b.positions[key]; ok {
+		b.targets[i] = unit.Target
+		return
+	}
+	b.positions[key] = len(b.sources)
+	b.sourceLangs = append(b.sourceLangs, unit.SourceLang)
+	b.targetLangs = append(b.targetLangs, unit.TargetLang)
+	b.sources = append(b.sources, unit.Source)
+	b.targets = append(b.targets, unit.Target)
+}
+
+func tmEntryResponse(entry database.TmEntry) TMEntry {
+	return TMEntry{
+		ID:         entry.ID,
+		CreatedAt:  entry.CreatedAt,
+		UpdatedAt:  entry.UpdatedAt,
+		SourceLang: entry.SourceLang,
+		TargetLang: entry.TargetLang,
+		Source:     entry.Source,
+		Target:     entry.Target,
+		Origin:     entry.Origin,
+		Status:     entry.Status,
+	}
+}
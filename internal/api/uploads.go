@@ -0,0 +1,153 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/o0n1x/mass-translate-package/provider"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/formats"
+)
+
+// handles file uploads and downloads. the uploaded file is copied to a temporary file as it
+// arrives and hashed on the way, so a cache hit never loads it into memory and a document
+// job holds a file instead of the whole binary until it translates it
+
+// form fields next to the file are short, e.g. target_lang
+const maxFormFieldSize = 64 << 10
+
+// a spooled upload, Close removes the temporary file
+type upload struct {
+	file *os.File
+	name string
+	size int64
+	// hex sha256 of the content, used in the cache key
+	hash string
+}
+
+// reads a multipart form with a file part named file and returns the upload and the other
+// fields. on false the error was already sent
+func (cfg *ApiConfig) receiveUpload(w http.ResponseWriter, r *http.Request, client provider.Client) (*upload, map[string]string, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxFileSize)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		errorRespond(w, formFileError(err))
+		return nil, nil, false
+	}
+
+	fields := map[string]string{}
+	var u *upload
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			u.Close()
+			errorRespond(w, formFileError(err))
+			return nil, nil, false
+		}
+
+		if part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, maxFormFieldSize+1))
+			if err != nil {
+				u.Close()
+				errorRespond(w, formFileError(err))
+				return nil, nil, false
+			}
+			if len(value) > maxFormFieldSize {
+				u.Close()
+				errorRespond(w, apperr.ErrInvalidRequest.WithMessagef("form field %s is too long", part.FormName()))
+				return nil, nil, false
+			}
+			fields[part.FormName()] = string(value)
+			continue
+		}
+		if part.FormName() != "file" || u != nil {
+			continue
+		}
+
+		// checked before spooling so a wrong file is turned away without reading it
+		if !cfg.isFileAllowed(client.Name(), part.FileName()) {
+			errorRespond(w, apperr.ErrInvalidFileType)
+			return nil, nil, false
+		}
+		u, err = spool(part)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			errorRespond(w, formFileError(err))
+			return nil, nil, false
+		}
+		if err != nil {
+			log.Printf("Error reading file: %v", err)
+			errorRespond(w, apperr.ErrInternal.WithMessage("failed to read file"))
+			return nil, nil, false
+		}
+		// the extension only says what the file claims to be
+		f, _ := formats.Lookup(u.name)
+		if !f.Matches(u.file, u.size) {
+			u.Close()
+			errorRespond(w, apperr.ErrInvalidFileType.WithMessagef("%s is not a valid %s file", part.FileName(), f.Name))
+			return nil, nil, false
+		}
+	}
+
+	if u == nil {
+		errorRespond(w, apperr.ErrFileRequired)
+		return nil, nil, false
+	}
+	return u, fields, true
+}
+
+func spool(part *multipart.Part) (*upload, error) {
+	file, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	u := &upload{file: file, name: part.FileName()}
+
+	hash := sha256.New()
+	u.size, err = io.Copy(io.MultiWriter(file, hash), part)
+	if err != nil {
+		u.Close()
+		return nil, err
+	}
+	u.hash = hex.EncodeToString(hash.Sum(nil))
+	return u, nil
+}
+
+// the whole file, only read when it goes to a provider since their clients take a []byte
+func (u *upload) bytes() ([]byte, error) {
+	data := make([]byte, u.size)
+	_, err := io.ReadFull(io.NewSectionReader(u.file, 0, u.size), data)
+	return data, err
+}
+
+// safe to call on a nil upload
+func (u *upload) Close() error {
+	if u == nil {
+		return nil
+	}
+	u.file.Close()
+	return os.Remove(u.file.Name())
+}
+
+// streams a translated file with Content-Length and range support. modified may be zero
+func fileRespond(w http.ResponseWriter, r *http.Request, content io.ReadSeeker, filename string, modified time.Time) {
+	contentType := "application/octet-stream"
+	if f, ok := formats.Lookup(filename); ok {
+		contentType = f.MIMEType
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"translated_%s\"", filename))
+	http.ServeContent(w, r, "", modified, content)
+}
@@ -0,0 +1,84 @@
+package apperr
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// handles the errors returned to API clients. every error has a stable code clients can
+// match on and the HTTP status it is served with. wrap them with %w to add context, e.g.
+// fmt.Errorf("%s | %w : %s", "FakeProvider", apperr.ErrInvalidTargetLang, req.To)
+
+type Error struct {
+	Code    string
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// errors are matched on their code, so a copy with a more specific message is still
+// errors.Is the sentinel it came from
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// returns a copy of the error with a more specific message for the client
+func (e *Error) WithMessage(msg string) *Error {
+	return &Error{Code: e.Code, Status: e.Status, Message: msg}
+}
+
+func (e *Error) WithMessagef(format string, args ...any) *Error {
+	return e.WithMessage(fmt.Sprintf(format, args...))
+}
+
+// request errors
+var (
+	ErrInvalidJSON            = &Error{"invalid_json", http.StatusBadRequest, "Invalid JSON in the request body"}
+	ErrInvalidRequest         = &Error{"invalid_request", http.StatusBadRequest, "invalid request"}
+	ErrInvalidID              = &Error{"invalid_id", http.StatusBadRequest, "invalid ID"}
+	ErrUnsupportedContentType = &Error{"unsupported_content_type", http.StatusBadRequest, "unsupported content type"}
+	ErrFileRequired           = &Error{"file_required", http.StatusBadRequest, "file required"}
+	ErrInvalidFileType        = &Error{"invalid_file_type", http.StatusBadRequest, "invalid file type"}
+	ErrFileTooLarge           = &Error{"file_too_large", http.StatusRequestEntityTooLarge, "file too large"}
+	ErrInvalidSourceLang      = &Error{"invalid_source_lang", http.StatusBadRequest, "Invalid Source Language"}
+	ErrInvalidTargetLang      = &Error{"invalid_target_lang", http.StatusBadRequest, "Invalid Target Language"}
+	ErrGlossaryMismatch       = &Error{"glossary_mismatch", http.StatusBadRequest, "glossary does not match the requested languages"}
+)
+
+// auth errors
+var (
+	ErrInvalidCredentials = &Error{"invalid_credentials", http.StatusUnauthorized, "Incorrect email or password"}
+	ErrUnauthorized       = &Error{"unauthorized", http.StatusUnauthorized, "Token missing or invalid, Please Login First"}
+	ErrForbidden          = &Error{"forbidden", http.StatusForbidden, "Forbidden"}
+)
+
+// lookup errors
+var (
+	ErrProviderNotFound = &Error{"provider_not_found", http.StatusNotFound, "unknown or disabled provider"}
+	ErrUserNotFound     = &Error{"user_not_found", http.StatusNotFound, "user not found"}
+	ErrDocumentNotFound = &Error{"document_not_found", http.StatusNotFound, "document not found"}
+	ErrGlossaryNotFound = &Error{"glossary_not_found", http.StatusNotFound, "glossary not found"}
+	ErrLogNotFound      = &Error{"log_not_found", http.StatusNotFound, "log not found"}
+	ErrAPIKeyNotFound   = &Error{"api_key_not_found", http.StatusNotFound, "API key not found"}
+	ErrOrgNotFound      = &Error{"organization_not_found", http.StatusNotFound, "organization not found"}
+	ErrMemberNotFound   = &Error{"member_not_found", http.StatusNotFound, "user is not a member of the organization"}
+	ErrAlreadyMember    = &Error{"already_member", http.StatusConflict, "user already belongs to an organization"}
+	ErrCacheKeyNotFound = &Error{"cache_key_not_found", http.StatusNotFound, "cache key not found"}
+	ErrTMEntryNotFound  = &Error{"tm_entry_not_found", http.StatusNotFound, "translation memory entry not found"}
+	ErrDocumentNotReady = &Error{"document_not_ready", http.StatusConflict, "document is not ready"}
+	ErrDocumentExpired  = &Error{"document_expired", http.StatusGone, "document expired"}
+)
+
+// limits and server side errors
+var (
+	ErrQuotaExceeded     = &Error{"quota_exceeded", http.StatusTooManyRequests, "quota exceeded"}
+	ErrTooManyAttempts   = &Error{"too_many_attempts", http.StatusTooManyRequests, "Too many failed logins, try again later"}
+	ErrRateLimited       = &Error{"rate_limited", http.StatusTooManyRequests, "Too many requests, slow down"}
+	ErrInternal          = &Error{"internal_error", http.StatusInternalServerError, "Internal server error"}
+	ErrTranslationFailed = &Error{"translation_failed", http.StatusInternalServerError, "Error translating"}
+	ErrNoProvider        = &Error{"no_provider", http.StatusServiceUnavailable, "no translation provider available"}
+)
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// API keys look like mt_<prefix>_<secret>. the prefix is stored in the clear to find and
+// identify the key, the whole key is only stored hashed
+
+const apiKeyTag = "mt"
+
+// scopes limit what a key can do on top of what its owner can do.
+// each scope includes the ones before it
+const (
+	ScopeTranslate = "translate"
+	ScopeUser      = "user"
+	ScopeAdmin     = "admin"
+)
+
+var scopeLevels = []string{ScopeTranslate, ScopeUser, ScopeAdmin}
+
+// returns the full key, to be shown once, and its prefix
+func MakeAPIKey() (string, string, error) {
+	prefix := make([]byte, 6)
+	secret := make([]byte, 32)
+	_, err := rand.Read(prefix)
+	if err != nil {
+		return "", "", err
+	}
+	_, err = rand.Read(secret)
+	if err != nil {
+		return "", "", err
+	}
+	p := hex.EncodeToString(prefix)
+	return fmt.Sprintf("%s_%s_%s", apiKeyTag, p, hex.EncodeToString(secret)), p, nil
+}
+
+// returns the prefix of a well formed key
+func APIKeyPrefix(key string) (string, error) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != apiKeyTag || parts[1] == "" || parts[2] == "" {
+		return "", fmt.Errorf("malformed API key")
+	}
+	return parts[1], nil
+}
+
+func CheckAPIKey(key string, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashToken(key)), []byte(hash)) == 1
+}
+
+// reads the key from X-API-Key or an "Authorization: ApiKey ..." header
+func GetAPIKey(headers http.Header) (string, bool) {
+	if key := headers.Get("X-API-Key"); key != "" {
+		return key, true
+	}
+	key, ok := strings.CutPrefix(headers.Get("Authorization"), "ApiKey ")
+	return key, ok && key != ""
+}
+
+func ValidScope(scope string) bool {
+	return slices.Contains(scopeLevels, scope)
+}
+
+// reports whether any of the scopes grants the required one
+func HasScope(scopes []string, required string) bool {
+	needed := slices.Index(scopeLevels, required)
+	if needed == -1 {
+		return false
+	}
+	for _, scope := range scopes {
+		if slices.Index(scopeLevels, scope) >= needed {
+			return true
+		}
+	}
+	return false
+}
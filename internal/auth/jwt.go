@@ -10,12 +10,15 @@ import (
 	"github.com/google/uuid"
 )
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+// the session ID ties the token to its refresh token, so revoking the session
+// invalidates the access token before it expires
+func MakeJWT(userID uuid.UUID, sessionID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
 		Issuer:    "chirpy",
 		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
 		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
 		Subject:   userID.String(),
+		ID:        sessionID.String(),
 	})
 
 	tokenstring, err := token.SignedString([]byte(tokenSecret))
@@ -25,22 +28,27 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 	return tokenstring, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+// returns the user and session IDs of a valid token
+func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, uuid.UUID, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(t *jwt.Token) (any, error) {
 		return []byte(tokenSecret), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 	if err != nil {
-		return uuid.UUID{}, err
+		return uuid.UUID{}, uuid.UUID{}, err
 	}
 	subject, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.UUID{}, err
+		return uuid.UUID{}, uuid.UUID{}, err
 	}
 	user, err := uuid.Parse(subject)
 	if err != nil {
-		return uuid.UUID{}, err
+		return uuid.UUID{}, uuid.UUID{}, err
+	}
+	session, err := uuid.Parse(token.Claims.(*jwt.RegisteredClaims).ID)
+	if err != nil {
+		return uuid.UUID{}, uuid.UUID{}, fmt.Errorf("token has no session: %w", err)
 	}
-	return user, nil
+	return user, session, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
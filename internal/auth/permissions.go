@@ -0,0 +1,40 @@
+package auth
+
+// permissions are granted through roles stored in the database, see sql/schema/011_roles.sql.
+// routes and handlers check these names
+
+const (
+	PermTranslate       = "translate"
+	PermGlossariesWrite = "glossaries.write"
+	PermGlossariesAdmin = "glossaries.admin"
+	PermDocumentsAdmin  = "documents.admin"
+	PermUsersRead       = "users.read"
+	PermUsersWrite      = "users.write"
+	PermRolesAssign     = "roles.assign"
+	PermUsageRead       = "usage.read"
+	PermQuotasWrite     = "quotas.write"
+	PermLogsRead        = "logs.read"
+	PermProvidersRead   = "providers.read"
+	PermOrgsAdmin       = "orgs.admin"
+	PermCacheAdmin      = "cache.admin"
+	PermTMAdmin         = "tm.admin"
+)
+
+// roles given by the server itself
+const (
+	RoleTranslator = "translator"
+	RoleSuperAdmin = "super-admin"
+)
+
+// the API key scope needed to use a permission, anything not listed is an admin permission
+var permissionScopes = map[string]string{
+	PermTranslate:       ScopeTranslate,
+	PermGlossariesWrite: ScopeUser,
+}
+
+func PermissionScope(permission string) string {
+	if scope, ok := permissionScopes[permission]; ok {
+		return scope
+	}
+	return ScopeAdmin
+}
@@ -0,0 +1,122 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+// circuit breaker that stops traffic to a failing provider for a cool down period.
+// after the cool down a single probe request is let through, if it succeeds the
+// breaker closes again otherwise it stays open for another cool down
+
+type State string
+
+const (
+	Closed   State = "closed"
+	Open     State = "open"
+	HalfOpen State = "half-open"
+)
+
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+
+	state         State
+	failures      int
+	openedAt      time.Time
+	probeInFlight bool
+	lastError     string
+}
+
+type Status struct {
+	State     State     `json:"state"`
+	Failures  int       `json:"consecutive_failures"`
+	OpenedAt  time.Time `json:"opened_at,omitzero"`
+	RetryAt   time.Time `json:"retry_at,omitzero"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// threshold is the number of consecutive failures that opens the breaker
+func New(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     Closed,
+	}
+}
+
+// reports whether a request may be sent. callers that get true must report
+// the outcome with Success or Failure
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Closed:
+		return true
+	case Open:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = HalfOpen
+		b.probeInFlight = true
+		return true
+	default:
+		// only one probe at a time while half open
+		if b.probeInFlight {
+			return false
+		}
+		b.probeInFlight = true
+		return true
+	}
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = Closed
+	b.failures = 0
+	b.probeInFlight = false
+	b.lastError = ""
+}
+
+func (b *Breaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probeInFlight = false
+	if err != nil {
+		b.lastError = err.Error()
+	}
+	if b.state == HalfOpen || b.failures >= b.threshold {
+		b.state = Open
+		b.openedAt = time.Now()
+	}
+}
+
+// gives back an allowed request without judging the provider, e.g. when the caller went away
+func (b *Breaker) Abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probeInFlight = false
+}
+
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := Status{
+		State:     b.state,
+		Failures:  b.failures,
+		LastError: b.lastError,
+	}
+	if b.state != Closed {
+		status.OpenedAt = b.openedAt
+		status.RetryAt = b.openedAt.Add(b.cooldown)
+	}
+	return status
+}
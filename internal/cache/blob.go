@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// large binaries are read back from redis in chunks with GETRANGE instead of one GET, so
+// serving a file never holds more than a chunk of it in memory
+
+const blobChunkSize = 1 << 20
+
+// a binary stored in redis. it is an io.ReadSeeker so http.ServeContent can answer range
+// requests from it. if the key expires while it is read, Read fails with io.ErrUnexpectedEOF
+type Blob struct {
+	ctx    context.Context
+	redis  *redis.Client
+	key    string
+	size   int64
+	offset int64
+
+	// the chunk read last and where it starts
+	chunk      []byte
+	chunkStart int64
+}
+
+// false if the key does not exist
+func openBlob(ctx context.Context, Redis *redis.Client, key string) (*Blob, bool, error) {
+	pipe := Redis.Pipeline()
+	exists := pipe.Exists(ctx, key)
+	size := pipe.StrLen(ctx, key)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if exists.Val() == 0 {
+		return nil, false, nil
+	}
+	return &Blob{ctx: ctx, redis: Redis, key: key, size: size.Val()}, true, nil
+}
+
+func (b *Blob) Size() int64 {
+	return b.size
+}
+
+func (b *Blob) Read(p []byte) (int, error) {
+	if b.offset >= b.size {
+		return 0, io.EOF
+	}
+	if b.offset < b.chunkStart || b.offset >= b.chunkStart+int64(len(b.chunk)) {
+		end := min(b.offset+blobChunkSize, b.size) - 1
+		chunk, err := b.redis.GetRange(b.ctx, b.key, b.offset, end).Bytes()
+		if err != nil {
+			return 0, err
+		}
+		if len(chunk) == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		b.chunk, b.chunkStart = chunk, b.offset
+	}
+	n := copy(p, b.chunk[b.offset-b.chunkStart:])
+	b.offset += int64(n)
+	return n, nil
+}
+
+func (b *Blob) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += b.offset
+	case io.SeekEnd:
+		offset += b.size
+	default:
+		return 0, errors.New("blob: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("blob: negative position")
+	}
+	b.offset = offset
+	return offset, nil
+}
@@ -0,0 +1,294 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/o0n1x/mass-translate-package/format"
+	"github.com/redis/go-redis/v9"
+)
+
+// keeps the translation cache within its limits. every entry is indexed with its expiry and
+// size, entries over MaxEntrySize are not cached at all and once the entries together
+// would pass MaxSize the ones closest to expiring are evicted first. documents are not
+// part of the cache and have their own TTL
+//
+// the scripts read and write the entries named in the index, which are not passed in KEYS,
+// so the cache needs a standalone Redis (or a primary with replicas), not Redis Cluster
+
+// set from the config at startup
+var (
+	// how long translations are kept per request type
+	TTLs = map[format.Format]time.Duration{
+		format.Text: time.Hour * 2,
+		format.File: time.Hour * 2,
+	}
+	// files at least LargeFileSize bytes are kept for LargeFileTTL instead
+	LargeFileSize int64 = 5 << 20
+	LargeFileTTL        = time.Minute * 30
+	MaxEntrySize  int64 = 20 << 20
+	MaxSize       int64 = 1 << 30
+)
+
+const (
+	indexKey     = "cache:index"
+	sizesKey     = "cache:sizes"
+	totalKey     = "cache:bytes"
+	evictionsKey = "cache:evictions"
+)
+
+// drops entries that expired on their own from the index, every script starts with it
+const pruneScript = `
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local function forget(key)
+	local size = tonumber(redis.call('HGET', KEYS[2], key) or '0')
+	redis.call('HDEL', KEYS[2], key)
+	redis.call('ZREM', KEYS[1], key)
+	redis.call('DECRBY', KEYS[3], size)
+end
+
+for _, key in ipairs(redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', now)) do
+	forget(key)
+end
+`
+
+var prune = redis.NewScript(pruneScript + `return 1`)
+
+// ARGV is the entry key, the value, its TTL in milliseconds and MaxSize
+var store = redis.NewScript(pruneScript + `
+local key = ARGV[1]
+local size = string.len(ARGV[2])
+forget(key)
+
+while tonumber(redis.call('GET', KEYS[3]) or '0') + size > tonumber(ARGV[4]) do
+	local soonest = redis.call('ZRANGE', KEYS[1], 0, 0)[1]
+	if not soonest then
+		break
+	end
+	redis.call('DEL', soonest)
+	forget(soonest)
+	redis.call('INCR', KEYS[4])
+end
+
+redis.call('SET', key, ARGV[2], 'PX', ARGV[3])
+redis.call('ZADD', KEYS[1], now + tonumber(ARGV[3]), key)
+redis.call('HSET', KEYS[2], key, size)
+redis.call('INCRBY', KEYS[3], size)
+return 1
+`)
+
+// ARGV are the keys to remove, keys that are not cache entries are left alone
+var remove = redis.NewScript(pruneScript + `
+local removed = 0
+for _, key in ipairs(ARGV) do
+	if redis.call('ZSCORE', KEYS[1], key) then
+		redis.call('DEL', key)
+		forget(key)
+		removed = removed + 1
+	end
+end
+return removed
+`)
+
+var budgetKeys = []string{indexKey, sizesKey, totalKey, evictionsKey}
+
+// caches the value unless it is over MaxEntrySize
+func set(ctx context.Context, Redis *redis.Client, key string, value []byte, ttl time.Duration) error {
+	if int64(len(value)) > MaxEntrySize {
+		return nil
+	}
+	return store.Run(ctx, Redis, budgetKeys, key, value, ttl.Milliseconds(), MaxSize).Err()
+}
+
+type entry struct {
+	key   string
+	value []byte
+}
+
+// like set for several entries in one round trip
+func setMany(ctx context.Context, Redis *redis.Client, entries []entry, ttl time.Duration) error {
+	// the script has to be loaded for EVALSHA to work inside a pipeline
+	err := store.Load(ctx, Redis).Err()
+	if err != nil {
+		return err
+	}
+
+	pipe := Redis.Pipeline()
+	for _, e := range entries {
+		if int64(len(e.value)) > MaxEntrySize {
+			continue
+		}
+		store.EvalSha(ctx, pipe, budgetKeys, e.key, e.value, ttl.Milliseconds(), MaxSize)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// the TTL for a translation of the request type with a result of size bytes
+func ttlFor(reqType format.Format, size int64) time.Duration {
+	if reqType == format.File && size >= LargeFileSize {
+		return LargeFileTTL
+	}
+	if ttl, ok := TTLs[reqType]; ok {
+		return ttl
+	}
+	return TTLs[format.Text]
+}
+
+type Stats struct {
+	Entries   int64
+	Size      int64
+	Evictions int64
+}
+
+func GetStats(ctx context.Context, Redis *redis.Client) (Stats, error) {
+	err := prune.Run(ctx, Redis, budgetKeys).Err()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	pipe := Redis.Pipeline()
+	entries := pipe.ZCard(ctx, indexKey)
+	size := pipe.Get(ctx, totalKey)
+	evictions := pipe.Get(ctx, evictionsKey)
+	_, err = pipe.Exec(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return Stats{}, err
+	}
+
+	stats := Stats{Entries: entries.Val()}
+	stats.Size, _ = size.Int64()
+	stats.Evictions, _ = evictions.Int64()
+	return stats, nil
+}
+
+// selects entries by provider and language pair, empty fields match anything. matching is
+// case insensitive
+type Filter struct {
+	Provider   string
+	SourceLang string
+	TargetLang string
+}
+
+type Entry struct {
+	Key        string
+	Provider   string
+	ReqType    format.Format
+	SourceLang string
+	TargetLang string
+	Size       int64
+	ExpiresAt  time.Time
+}
+
+// entries matching the filter, the ones expiring first come first
+func GetEntries(ctx context.Context, Redis *redis.Client, filter Filter) ([]Entry, error) {
+	err := prune.Run(ctx, Redis, budgetKeys).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	var cursor uint64
+	for {
+		page, next, err := Redis.ZScan(ctx, indexKey, cursor, "", 1000).Result()
+		if err != nil {
+			return nil, err
+		}
+		// pages are member, score pairs
+		keys := []string{}
+		expiries := map[string]string{}
+		for i := 0; i+1 < len(page); i += 2 {
+			keys = append(keys, page[i])
+			expiries[page[i]] = page[i+1]
+		}
+		if len(keys) > 0 {
+			sizes, err := Redis.HMGet(ctx, sizesKey, keys...).Result()
+			if err != nil {
+				return nil, err
+			}
+			for i, key := range keys {
+				entry, ok := parseKey(key)
+				if !ok || !filter.matches(entry) {
+					continue
+				}
+				size, _ := sizes[i].(string)
+				entry.Size, _ = strconv.ParseInt(size, 10, 64)
+				expiry, _ := strconv.ParseFloat(expiries[key], 64)
+				entry.ExpiresAt = time.UnixMilli(int64(expiry))
+				entries = append(entries, entry)
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b Entry) int { return a.ExpiresAt.Compare(b.ExpiresAt) })
+	return entries, nil
+}
+
+// removes every entry matching the filter and returns how many there were
+func Purge(ctx context.Context, Redis *redis.Client, filter Filter) (int64, error) {
+	entries, err := GetEntries(ctx, Redis, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int64
+	for start := 0; start < len(entries); start += 500 {
+		batch := []any{}
+		for _, entry := range entries[start:min(start+500, len(entries))] {
+			batch = append(batch, entry.Key)
+		}
+		n, err := remove.Run(ctx, Redis, budgetKeys, batch...).Int64()
+		if err != nil {
+			return removed, err
+		}
+		removed += n
+	}
+	return removed, nil
+}
+
+// removes a single entry, false if there is no such cache entry
+func Invalidate(ctx context.Context, Redis *redis.Client, key string) (bool, error) {
+	n, err := remove.Run(ctx, Redis, budgetKeys, key).Int64()
+	return n > 0, err
+}
+
+// keys look like v1:segment:DeepL:EN:FR:<hash>, see Key. keys of older versions are skipped,
+// they expire on their own
+func parseKey(key string) (Entry, bool) {
+	parts := strings.Split(key, ":")
+	if len(parts) != 6 || parts[0] != fmt.Sprintf("v%d", keyVersion) {
+		return Entry{}, false
+	}
+	entry := Entry{
+		Key:        key,
+		Provider:   parts[2],
+		SourceLang: parts[3],
+		TargetLang: parts[4],
+	}
+	switch parts[1] {
+	case "segment":
+		entry.ReqType = format.Text
+	case "file":
+		entry.ReqType = format.File
+	default:
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+func (f Filter) matches(entry Entry) bool {
+	return (f.Provider == "" || strings.EqualFold(f.Provider, entry.Provider)) &&
+		(f.SourceLang == "" || strings.EqualFold(f.SourceLang, entry.SourceLang)) &&
+		(f.TargetLang == "" || strings.EqualFold(f.TargetLang, entry.TargetLang))
+}
@@ -2,63 +2,109 @@ package cache
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/o0n1x/mass-translate-package/format"
-	"github.com/o0n1x/mass-translate-package/provider"
+	"github.com/o0n1x/mass-translate-server/internal/metrics"
 	"github.com/redis/go-redis/v9"
 )
 
 // handles anything related to redis caching
 
-const translationTTL = time.Hour * 2
+// text is cached per segment, so a batch with one new sentence only sends that sentence to
+// the provider. each segment has its own key and the translation is stored as plain text
 
-func SetCache(ctx context.Context, Redis *redis.Client, clienttype provider.Provider, req provider.Request, translation provider.Response) error {
+// looks up every segment in one MGET. the translations are in the order of segments, hits
+// says which of them were found
+func GetSegments(ctx context.Context, Redis *redis.Client, key Key, segments []string) ([]string, []bool, error) {
+	translations := make([]string, len(segments))
+	hits := make([]bool, len(segments))
+	if len(segments) == 0 {
+		return translations, hits, nil
+	}
 
-	data, err := json.Marshal(translation)
+	keys := make([]string, len(segments))
+	for i, segment := range segments {
+		keys[i] = key.segment(segment)
+	}
+	values, err := Redis.MGet(ctx, keys...).Result()
 	if err != nil {
-		return err
+		metrics.CacheError()
+		return translations, hits, err
 	}
 
-	status := Redis.Set(ctx, getCacheKey(clienttype, req), data, translationTTL)
-	if status.Err() != nil {
-		return status.Err()
+	for i, value := range values {
+		translation, ok := value.(string)
+		if !ok {
+			metrics.CacheMiss()
+			continue
+		}
+		metrics.CacheHit()
+		translations[i], hits[i] = translation, true
 	}
-	return nil
+	return translations, hits, nil
 }
 
-func getCacheKey(clienttype provider.Provider, req provider.Request) string {
-	var reqHash string
-	if req.ReqType == format.Text {
-		h := sha256.Sum256([]byte(strings.Join(req.Text, "|")))
-		reqHash = hex.EncodeToString(h[:])
-
-	} else {
-		h := sha256.Sum256(req.Binary)
-		reqHash = hex.EncodeToString(h[:])
+// caches each segment with the translation at the same index
+func SetSegments(ctx context.Context, Redis *redis.Client, key Key, segments []string, translations []string) error {
+	if len(translations) != len(segments) {
+		return fmt.Errorf("got %d translations for %d segments", len(translations), len(segments))
 	}
+	entries := make([]entry, len(segments))
+	for i, segment := range segments {
+		entries[i] = entry{key: key.segment(segment), value: []byte(translations[i])}
+	}
+	return setMany(ctx, Redis, entries, ttlFor(format.Text, 0))
+}
+
+// translated files are stored as raw bytes rather than a JSON provider.Response, so they are
+// not base64 inflated and can be streamed back in chunks. hash is the hex sha256 of the
+// uploaded file, which the caller already has from receiving it
 
-	return fmt.Sprintf("translate:%s:%s:%s:%s", clienttype, req.From, req.To, reqHash)
+func SetFile(ctx context.Context, Redis *redis.Client, key Key, hash string, binary []byte) error {
+	return set(ctx, Redis, key.file(hash), binary, ttlFor(format.File, int64(len(binary))))
 }
 
-func GetCache(ctx context.Context, Redis *redis.Client, clienttype provider.Provider, req provider.Request) (provider.Response, bool, error) {
-	result, err := Redis.Get(ctx, getCacheKey(clienttype, req)).Result()
-	if errors.Is(err, redis.Nil) {
-		return provider.Response{}, false, nil
+func GetFile(ctx context.Context, Redis *redis.Client, key Key, hash string) (*Blob, bool, error) {
+	blob, found, err := openBlob(ctx, Redis, key.file(hash))
+	switch {
+	case err != nil:
+		metrics.CacheError()
+	case found:
+		metrics.CacheHit()
+	default:
+		metrics.CacheMiss()
 	}
-	if err != nil {
-		return provider.Response{}, false, err
-	}
-	var params provider.Response
-	err = json.Unmarshal([]byte(result), &params)
-	if err != nil {
-		return provider.Response{}, false, err
-	}
-	return params, true, nil
+	return blob, found, err
+}
+
+// document binaries for async translation jobs, metadata lives in postgres
+
+const documentTTL = time.Hour * 24
+
+func getDocumentKey(id uuid.UUID) string {
+	return fmt.Sprintf("document:%s", id)
+}
+
+func SetDocument(ctx context.Context, Redis *redis.Client, id uuid.UUID, binary []byte) error {
+	return Redis.Set(ctx, getDocumentKey(id), binary, documentTTL).Err()
+}
+
+// stores a cached translation as the document's result without reading it out of redis
+func SetDocumentFrom(ctx context.Context, Redis *redis.Client, id uuid.UUID, blob *Blob) error {
+	pipe := Redis.TxPipeline()
+	pipe.Copy(ctx, blob.key, getDocumentKey(id), 0, true)
+	pipe.Expire(ctx, getDocumentKey(id), documentTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func GetDocument(ctx context.Context, Redis *redis.Client, id uuid.UUID) (*Blob, bool, error) {
+	return openBlob(ctx, Redis, getDocumentKey(id))
+}
+
+func DeleteDocument(ctx context.Context, Redis *redis.Client, id uuid.UUID) error {
+	return Redis.Del(ctx, getDocumentKey(id)).Err()
 }
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"path/filepath"
+	"strings"
+
+	"github.com/o0n1x/mass-translate-package/format"
+	"github.com/o0n1x/mass-translate-package/lang"
+	"github.com/o0n1x/mass-translate-package/provider"
+)
+
+// builds cache keys from everything that can change a translation: the provider and its
+// version, the request type, both languages, the file extension and the glossary version.
+// all of it goes into one hash, so two requests that differ in any of
+// them never share a key. the readable parts in front are only there for admins to filter on
+
+// bump to drop every cached translation on the next deploy, e.g. when the key layout or the
+// meaning of a stored value changes
+const keyVersion = 1
+
+type Key struct {
+	provider        provider.Provider
+	providerVersion string
+	reqType         format.Format
+	from            string
+	to              string
+	ext             string
+	glossary        string
+}
+
+// the key for translations of req by client. the content itself is added per segment or
+// file by the caller
+func NewKey(client provider.Client, req provider.Request) Key {
+	key := Key{
+		provider:        client.Name(),
+		providerVersion: client.Version(),
+		reqType:         req.ReqType,
+		from:            sourceLang(req.From),
+		to:              req.To.String(),
+	}
+	// the name does not change the translation but the type of the file does
+	if req.ReqType == format.File {
+		key.ext = strings.ToLower(filepath.Ext(req.FileName))
+	}
+	return key
+}
+
+// version is the glossary's version, empty for none
+func (k Key) WithGlossary(version string) Key {
+	k.glossary = version
+	return k
+}
+
+func (k Key) segment(text string) string {
+	return k.build("segment", text)
+}
+
+// hash is the hex sha256 of the file
+func (k Key) file(hash string) string {
+	return k.build("file", hash)
+}
+
+// e.g. v1:segment:DeepL:auto:FR:<hash>
+func (k Key) build(kind string, content string) string {
+	h := sha256.New()
+	field(h, "kind", kind)
+	field(h, "provider", string(k.provider))
+	field(h, "provider_version", k.providerVersion)
+	field(h, "req_type", k.reqType.String())
+	field(h, "from", k.from)
+	field(h, "to", k.to)
+	field(h, "ext", k.ext)
+	field(h, "glossary", k.glossary)
+	field(h, "content", content)
+
+	from := k.from
+	if from == "" {
+		from = "auto"
+	}
+	return fmt.Sprintf("v%d:%s:%s:%s:%s:%s", keyVersion, kind, k.provider, from, k.to, hex.EncodeToString(h.Sum(nil)))
+}
+
+// length prefixed so no two different lists of fields write the same bytes
+func field(h hash.Hash, name string, value string) {
+	fmt.Fprintf(h, "%d:%s%d:%s", len(name), name, len(value), value)
+}
+
+// a missing source language, auto and AutoDetect all let the provider detect it, so they
+// share entries. they are shown as auto in the key
+func sourceLang(from lang.Language) string {
+	if from == lang.AutoDetect || strings.EqualFold(from.String(), "auto") {
+		return ""
+	}
+	return from.String()
+}
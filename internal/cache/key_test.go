@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/o0n1x/mass-translate-package/format"
+	"github.com/o0n1x/mass-translate-package/lang"
+	"github.com/o0n1x/mass-translate-package/provider"
+)
+
+// short values from a small set, so values that look alike once joined, such as "a:" and
+// ":a", meet
+var keyValues = []string{"", "a", "b", "a:", ":a", "a:b", "1:a", "auto", "Nil", "DeepL", "v1", ".txt"}
+
+type keyInput struct {
+	Provider        string
+	ProviderVersion string
+	File            bool
+	From            string
+	To              string
+	Ext             string
+	Glossary        string
+	Content         string
+}
+
+func (keyInput) Generate(r *rand.Rand, size int) reflect.Value {
+	in := keyInput{}
+	for range 8 {
+		in = in.mutate(r)
+	}
+	return reflect.ValueOf(in)
+}
+
+// changes one field, keys of inputs that differ in a field or two are the likeliest to meet
+func (in keyInput) mutate(r *rand.Rand) keyInput {
+	value := keyValues[r.Intn(len(keyValues))]
+	switch r.Intn(8) {
+	case 0:
+		in.Provider = value
+	case 1:
+		in.ProviderVersion = value
+	case 2:
+		in.File = !in.File
+	case 3:
+		in.From = value
+	case 4:
+		in.To = value
+	case 5:
+		in.Ext = value
+	case 6:
+		in.Glossary = value
+	case 7:
+		in.Content = value
+	}
+	return in
+}
+
+// an input and a copy of it with up to three fields changed
+type keyPair struct {
+	a keyInput
+	b keyInput
+}
+
+func (keyPair) Generate(r *rand.Rand, size int) reflect.Value {
+	a := keyInput{}.Generate(r, size).Interface().(keyInput)
+	b := a
+	for range 1 + r.Intn(3) {
+		b = b.mutate(r)
+	}
+	return reflect.ValueOf(keyPair{a: a, b: b})
+}
+
+func (in keyInput) key() Key {
+	reqType := format.Text
+	if in.File {
+		reqType = format.File
+	}
+	return Key{
+		provider:        provider.Provider(in.Provider),
+		providerVersion: in.ProviderVersion,
+		reqType:         reqType,
+		from:            sourceLang(lang.Language(in.From)),
+		to:              in.To,
+		ext:             in.Ext,
+		glossary:        in.Glossary,
+	}
+}
+
+// the input as the key sees it, auto detection is one source language
+func (in keyInput) normalized() keyInput {
+	in.From = sourceLang(lang.Language(in.From))
+	return in
+}
+
+func TestKeysOfDifferentRequestsDiffer(t *testing.T) {
+	property := func(pair keyPair) bool {
+		a, b := pair.a, pair.b
+		same := a.normalized() == b.normalized()
+		segmentsMatch := a.key().segment(a.Content) == b.key().segment(b.Content)
+		filesMatch := a.key().file(a.Content) == b.key().file(b.Content)
+		return same == segmentsMatch && same == filesMatch
+	}
+	err := quick.Check(property, &quick.Config{MaxCount: 20000})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSegmentAndFileKeysDiffer(t *testing.T) {
+	property := func(in keyInput) bool {
+		return in.key().segment(in.Content) != in.key().file(in.Content)
+	}
+	err := quick.Check(property, nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+type stubClient struct {
+	name    provider.Provider
+	version string
+}
+
+func (c stubClient) Translate(context.Context, provider.Request) (provider.Response, error) {
+	return provider.Response{}, nil
+}
+func (c stubClient) GetCost(provider.Request) float32  { return 0 }
+func (c stubClient) GetCharCount(provider.Request) int { return 0 }
+func (c stubClient) Name() provider.Provider           { return c.name }
+func (c stubClient) Version() string                   { return c.version }
+
+func TestAutoDetectSharesKeys(t *testing.T) {
+	client := stubClient{name: "DeepL", version: "v1"}
+	want := NewKey(client, provider.Request{ReqType: format.Text, To: "FR"}).segment("Hello")
+	for _, from := range []lang.Language{"auto", "AUTO", lang.AutoDetect} {
+		got := NewKey(client, provider.Request{ReqType: format.Text, From: from, To: "FR"}).segment("Hello")
+		if got != want {
+			t.Errorf("source language %q got key %s, want %s", from, got, want)
+		}
+	}
+	detected := NewKey(client, provider.Request{ReqType: format.Text, From: "EN", To: "FR"}).segment("Hello")
+	if detected == want {
+		t.Errorf("source language EN shares the auto detect key %s", want)
+	}
+}
+
+func TestFileNameOnlyChangesKeyByExtension(t *testing.T) {
+	client := stubClient{name: "DeepL", version: "v1"}
+	key := func(name string) string {
+		return NewKey(client, provider.Request{ReqType: format.File, FileName: name, To: "FR"}).file("hash")
+	}
+	if key("a.txt") != key("b.TXT") {
+		t.Error("files with the same extension got different keys")
+	}
+	if key("a.txt") == key("a.docx") {
+		t.Error("files with different extensions share a key")
+	}
+}
@@ -1,3 +1,369 @@
 package config
 
-// handles config reading and writing
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// handles config reading and writing. settings come from an optional YAML file
+// (CONFIG_FILE, config.yaml by default) and are overridden by environment variables,
+// so existing .env setups keep working without a file
+
+const DefaultFile = "config.yaml"
+
+type Config struct {
+	ListenAddr string `yaml:"listen_addr"`
+	DBURL      string `yaml:"db_url"`
+	RedisURL   string `yaml:"redis_url"`
+	JWTSecret  string `yaml:"jwt_secret"`
+
+	TokenTTL        time.Duration `yaml:"token_ttl"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
+	MaxFileSize     int64         `yaml:"max_file_size"`
+
+	// cache_ttl is for text, files at least cache_large_file_size bytes get cache_large_file_ttl.
+	// entries over cache_max_entry_size are not cached and the entries closest to expiring are
+	// evicted once the cache would pass cache_max_size
+	CacheTTL           time.Duration `yaml:"cache_ttl"`
+	CacheFileTTL       time.Duration `yaml:"cache_file_ttl"`
+	CacheLargeFileTTL  time.Duration `yaml:"cache_large_file_ttl"`
+	CacheLargeFileSize int64         `yaml:"cache_large_file_size"`
+	CacheMaxEntrySize  int64         `yaml:"cache_max_entry_size"`
+	CacheMaxSize       int64         `yaml:"cache_max_size"`
+
+	// failed logins before an email is locked, and for how long
+	LoginMaxFailures int64         `yaml:"login_max_failures"`
+	LoginLockout     time.Duration `yaml:"login_lockout"`
+
+	// requests per minute for users whose roles set no limit, and per client IP (0 is off)
+	RateLimit   int64 `yaml:"rate_limit"`
+	IPRateLimit int64 `yaml:"ip_rate_limit"`
+
+	// addresses or CIDR ranges of proxies in front of the server. requests from them are
+	// attributed to the client in X-Forwarded-For
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	// translation memory entries at least tm_threshold similar (0 to 1) are suggested, and
+	// tm_record stores machine translations as pending entries
+	TMThreshold float64 `yaml:"tm_threshold"`
+	TMRecord    bool    `yaml:"tm_record"`
+
+	Admin struct {
+		Email    string `yaml:"email"`
+		Password string `yaml:"password"`
+	} `yaml:"admin"`
+
+	// provider names are matched case insensitively, e.g. deepl
+	Providers         []string            `yaml:"providers"`
+	FallbackProviders []string            `yaml:"fallback_providers"`
+	ProviderKeys      map[string]string   `yaml:"provider_keys"`
+	AllowedExtensions map[string][]string `yaml:"allowed_extensions"`
+}
+
+func Default() Config {
+	return Config{
+		ListenAddr:         ":8080",
+		TokenTTL:           time.Hour,
+		RefreshTokenTTL:    time.Hour * 24 * 30,
+		CacheTTL:           time.Hour * 2,
+		CacheFileTTL:       time.Hour * 2,
+		CacheLargeFileTTL:  time.Minute * 30,
+		CacheLargeFileSize: 5 << 20,
+		CacheMaxEntrySize:  20 << 20,
+		CacheMaxSize:       1 << 30,
+		MaxFileSize:        50 << 20,
+		LoginMaxFailures:   5,
+		LoginLockout:       time.Minute * 15,
+		RateLimit:          60,
+		IPRateLimit:        600,
+		TMThreshold:        0.7,
+		TMRecord:           true,
+		Providers:          []string{"deepl"},
+		AllowedExtensions:  map[string][]string{},
+		ProviderKeys:       map[string]string{},
+	}
+}
+
+// reads the config file if there is one, applies the environment on top and validates
+// the result. every problem is reported at once so a bad deploy fails with the full list
+func Load() (Config, error) {
+	cfg := Default()
+
+	path, explicit := os.LookupEnv("CONFIG_FILE")
+	if !explicit {
+		path = DefaultFile
+	}
+	err := cfg.loadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		err = nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+
+	err = errors.Join(cfg.loadEnv(), cfg.validate())
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid config:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// extensions the provider accepts, providers without an entry accept every type they support
+func (c Config) Extensions(provider string) []string {
+	return c.AllowedExtensions[strings.ToLower(provider)]
+}
+
+// the trusted proxies as ranges, a single address is a range of one
+func (c Config) Proxies() ([]netip.Prefix, error) {
+	proxies := []netip.Prefix{}
+	for _, proxy := range c.TrustedProxies {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(proxy)
+			if addrErr != nil {
+				return nil, fmt.Errorf("TRUSTED_PROXIES entry %q is not an address or a CIDR range", proxy)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+// the API key for a provider, empty for providers that need none
+func (c Config) ProviderKey(provider string) string {
+	return c.ProviderKeys[strings.ToLower(provider)]
+}
+
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// maps are decoded on their own so file entries replace the defaults per provider
+	keys, extensions := c.ProviderKeys, c.AllowedExtensions
+	c.ProviderKeys, c.AllowedExtensions = nil, nil
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	err = decoder.Decode(c)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("Error reading config file %s: %w", path, err)
+	}
+
+	for name, key := range c.ProviderKeys {
+		keys[strings.ToLower(name)] = key
+	}
+	for name, list := range c.AllowedExtensions {
+		extensions[strings.ToLower(name)] = list
+	}
+	c.ProviderKeys, c.AllowedExtensions = keys, extensions
+	return nil
+}
+
+// environment variables win over the file, empty ones are ignored. provider keys and extensions are read per
+// enabled provider, e.g. DEEPL_API and DEEPL_EXTENSIONS=.srt,.txt
+func (c *Config) loadEnv() error {
+	errs := []error{}
+
+	setString(&c.ListenAddr, "LISTEN_ADDR")
+	setString(&c.DBURL, "DB_URL")
+	setString(&c.RedisURL, "REDIS_URL")
+	setString(&c.JWTSecret, "SECRET_JWT")
+	setString(&c.Admin.Email, "ADMIN_EMAIL")
+	setString(&c.Admin.Password, "ADMIN_PASSWORD")
+	setList(&c.Providers, "PROVIDERS")
+	setList(&c.FallbackProviders, "FALLBACK_PROVIDERS")
+	setList(&c.TrustedProxies, "TRUSTED_PROXIES")
+
+	errs = append(errs, setDuration(&c.TokenTTL, "TOKEN_TTL"))
+	errs = append(errs, setDuration(&c.RefreshTokenTTL, "REFRESH_TOKEN_TTL"))
+	errs = append(errs, setDuration(&c.CacheTTL, "CACHE_TTL"))
+	errs = append(errs, setDuration(&c.CacheFileTTL, "CACHE_FILE_TTL"))
+	errs = append(errs, setDuration(&c.CacheLargeFileTTL, "CACHE_LARGE_FILE_TTL"))
+	errs = append(errs, setInt(&c.CacheLargeFileSize, "CACHE_LARGE_FILE_SIZE"))
+	errs = append(errs, setInt(&c.CacheMaxEntrySize, "CACHE_MAX_ENTRY_SIZE"))
+	errs = append(errs, setInt(&c.CacheMaxSize, "CACHE_MAX_SIZE"))
+	errs = append(errs, setInt(&c.MaxFileSize, "MAX_FILE_SIZE"))
+	errs = append(errs, setInt(&c.LoginMaxFailures, "LOGIN_MAX_FAILURES"))
+	errs = append(errs, setDuration(&c.LoginLockout, "LOGIN_LOCKOUT"))
+	errs = append(errs, setInt(&c.RateLimit, "RATE_LIMIT"))
+	errs = append(errs, setInt(&c.IPRateLimit, "IP_RATE_LIMIT"))
+	errs = append(errs, setFloat(&c.TMThreshold, "TM_THRESHOLD"))
+	errs = append(errs, setBool(&c.TMRecord, "TM_RECORD"))
+
+	for _, name := range c.Providers {
+		name = strings.ToLower(name)
+		prefix := strings.ToUpper(name)
+		if key := os.Getenv(prefix + "_API"); key != "" {
+			c.ProviderKeys[name] = key
+		}
+		if extensions := os.Getenv(prefix + "_EXTENSIONS"); extensions != "" {
+			c.AllowedExtensions[name] = splitList(extensions)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) validate() error {
+	errs := []error{}
+	required := []struct {
+		env   string
+		key   string
+		value string
+	}{
+		{"DB_URL", "db_url", c.DBURL},
+		{"REDIS_URL", "redis_url", c.RedisURL},
+		{"SECRET_JWT", "jwt_secret", c.JWTSecret},
+	}
+	for _, field := range required {
+		if field.value == "" {
+			errs = append(errs, fmt.Errorf("%s is required, set it in the environment or as %s in the config file", field.env, field.key))
+		}
+	}
+	if c.ListenAddr == "" {
+		errs = append(errs, fmt.Errorf("LISTEN_ADDR can not be empty"))
+	}
+	if c.TokenTTL <= 0 {
+		errs = append(errs, fmt.Errorf("TOKEN_TTL must be positive, got %v", c.TokenTTL))
+	}
+	if c.RefreshTokenTTL < c.TokenTTL {
+		errs = append(errs, fmt.Errorf("REFRESH_TOKEN_TTL must be at least TOKEN_TTL, got %v", c.RefreshTokenTTL))
+	}
+	if c.CacheTTL <= 0 {
+		errs = append(errs, fmt.Errorf("CACHE_TTL must be positive, got %v", c.CacheTTL))
+	}
+	if c.CacheFileTTL <= 0 {
+		errs = append(errs, fmt.Errorf("CACHE_FILE_TTL must be positive, got %v", c.CacheFileTTL))
+	}
+	if c.CacheLargeFileTTL <= 0 {
+		errs = append(errs, fmt.Errorf("CACHE_LARGE_FILE_TTL must be positive, got %v", c.CacheLargeFileTTL))
+	}
+	if c.CacheLargeFileSize <= 0 {
+		errs = append(errs, fmt.Errorf("CACHE_LARGE_FILE_SIZE must be positive, got %d", c.CacheLargeFileSize))
+	}
+	if c.CacheMaxEntrySize <= 0 {
+		errs = append(errs, fmt.Errorf("CACHE_MAX_ENTRY_SIZE must be positive, got %d", c.CacheMaxEntrySize))
+	}
+	if c.CacheMaxSize < c.CacheMaxEntrySize {
+		errs = append(errs, fmt.Errorf("CACHE_MAX_SIZE must be at least CACHE_MAX_ENTRY_SIZE, got %d", c.CacheMaxSize))
+	}
+	if c.MaxFileSize <= 0 {
+		errs = append(errs, fmt.Errorf("MAX_FILE_SIZE must be positive, got %d", c.MaxFileSize))
+	}
+	if c.LoginMaxFailures <= 0 {
+		errs = append(errs, fmt.Errorf("LOGIN_MAX_FAILURES must be positive, got %d", c.LoginMaxFailures))
+	}
+	if c.LoginLockout <= 0 {
+		errs = append(errs, fmt.Errorf("LOGIN_LOCKOUT must be positive, got %v", c.LoginLockout))
+	}
+	if c.RateLimit <= 0 {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT must be positive, got %d", c.RateLimit))
+	}
+	if c.IPRateLimit < 0 {
+		errs = append(errs, fmt.Errorf("IP_RATE_LIMIT can not be negative, got %d", c.IPRateLimit))
+	}
+	_, err := c.Proxies()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if c.TMThreshold <= 0 || c.TMThreshold > 1 {
+		errs = append(errs, fmt.Errorf("TM_THRESHOLD must be above 0 and at most 1, got %v", c.TMThreshold))
+	}
+	if len(c.Providers) == 0 {
+		errs = append(errs, fmt.Errorf("PROVIDERS needs at least one provider"))
+	}
+	for provider, extensions := range c.AllowedExtensions {
+		for _, ext := range extensions {
+			if !strings.HasPrefix(ext, ".") {
+				errs = append(errs, fmt.Errorf("extension %q for %s must start with a dot", ext, provider))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func setString(field *string, env string) {
+	if value := os.Getenv(env); value != "" {
+		*field = value
+	}
+}
+
+func setList(field *[]string, env string) {
+	if value := os.Getenv(env); value != "" {
+		*field = splitList(value)
+	}
+}
+
+func setDuration(field *time.Duration, env string) error {
+	value := os.Getenv(env)
+	if value == "" {
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s must be a duration like 1h or 30m, got %q", env, value)
+	}
+	*field = parsed
+	return nil
+}
+
+func setInt(field *int64, env string) error {
+	value := os.Getenv(env)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s must be a whole number, got %q", env, value)
+	}
+	*field = parsed
+	return nil
+}
+
+func setFloat(field *float64, env string) error {
+	value := os.Getenv(env)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%s must be a number, got %q", env, value)
+	}
+	*field = parsed
+	return nil
+}
+
+func setBool(field *bool, env string) error {
+	value := os.Getenv(env)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%s must be true or false, got %q", env, value)
+	}
+	*field = parsed
+	return nil
+}
+
+// comma separated, blanks are dropped
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,90 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: audit.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const createAuditEvent = `-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (id, created_at, action, target, ip, details, actor_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+`
+
+type CreateAuditEventParams struct {
+	Action  string
+	Target  string
+	Ip      string
+	Details sql.NullString
+	ActorID uuid.NullUUID
+}
+
+func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) error {
+	_, err := q.db.ExecContext(ctx, createAuditEvent,
+		arg.Action,
+		arg.Target,
+		arg.Ip,
+		arg.Details,
+		arg.ActorID,
+	)
+	return err
+}
+
+const getAuditEvents = `-- name: GetAuditEvents :many
+SELECT id, created_at, action, target, ip, details, actor_id
+FROM audit_events
+WHERE ($1::text IS NULL OR action = $1)
+ORDER BY created_at DESC
+LIMIT $3 OFFSET $2
+`
+
+type GetAuditEventsParams struct {
+	Action sql.NullString
+	Offset int32
+	Limit  int32
+}
+
+func (q *Queries) GetAuditEvents(ctx context.Context, arg GetAuditEventsParams) ([]AuditEvent, error) {
+	rows, err := q.db.QueryContext(ctx, getAuditEvents, arg.Action, arg.Offset, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditEvent
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.Action,
+			&i.Target,
+			&i.Ip,
+			&i.Details,
+			&i.ActorID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
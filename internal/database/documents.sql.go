@@ -0,0 +1,148 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: documents.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createDocument = `-- name: CreateDocument :one
+INSERT INTO documents (id, created_at, updated_at, provider,file_name,from_lang,to_lang,status,user_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING id, created_at, updated_at, provider, file_name, from_lang, to_lang, status, error, user_id
+`
+
+type CreateDocumentParams struct {
+	Provider string
+	FileName string
+	FromLang string
+	ToLang   string
+	Status   string
+	UserID   uuid.UUID
+}
+
+func (q *Queries) CreateDocument(ctx context.Context, arg CreateDocumentParams) (Document, error) {
+	row := q.db.QueryRowContext(ctx, createDocument,
+		arg.Provider,
+		arg.FileName,
+		arg.FromLang,
+		arg.ToLang,
+		arg.Status,
+		arg.UserID,
+	)
+	var i Document
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Provider,
+		&i.FileName,
+		&i.FromLang,
+		&i.ToLang,
+		&i.Status,
+		&i.Error,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const deleteDocument = `-- name: DeleteDocument :exec
+DELETE FROM documents
+WHERE id=$1
+`
+
+func (q *Queries) DeleteDocument(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteDocument, id)
+	return err
+}
+
+const failStaleDocuments = `-- name: FailStaleDocuments :execrows
+UPDATE documents
+SET status = 'error', error = $1, updated_at = NOW()
+WHERE status IN ('pending', 'translating') AND updated_at < $2
+`
+
+type FailStaleDocumentsParams struct {
+	Error     sql.NullString
+	UpdatedAt time.Time
+}
+
+func (q *Queries) FailStaleDocuments(ctx context.Context, arg FailStaleDocumentsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, failStaleDocuments, arg.Error, arg.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getDocument = `-- name: GetDocument :one
+SELECT id, created_at, updated_at, provider, file_name, from_lang, to_lang, status, error, user_id
+FROM documents
+WHERE id=$1
+`
+
+func (q *Queries) GetDocument(ctx context.Context, id uuid.UUID) (Document, error) {
+	row := q.db.QueryRowContext(ctx, getDocument, id)
+	var i Document
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Provider,
+		&i.FileName,
+		&i.FromLang,
+		&i.ToLang,
+		&i.Status,
+		&i.Error,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const updateDocumentStatus = `-- name: UpdateDocumentStatus :one
+UPDATE documents
+SET status = $2 , error = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, provider, file_name, from_lang, to_lang, status, error, user_id
+`
+
+type UpdateDocumentStatusParams struct {
+	ID     uuid.UUID
+	Status string
+	Error  sql.NullString
+}
+
+func (q *Queries) UpdateDocumentStatus(ctx context.Context, arg UpdateDocumentStatusParams) (Document, error) {
+	row := q.db.QueryRowContext(ctx, updateDocumentStatus, arg.ID, arg.Status, arg.Error)
+	var i Document
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Provider,
+		&i.FileName,
+		&i.FromLang,
+		&i.ToLang,
+		&i.Status,
+		&i.Error,
+		&i.UserID,
+	)
+	return i, err
+}
@@ -0,0 +1,160 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: getLogs.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const getLog = `-- name: GetLog :one
+SELECT logs.id, logs.created_at, logs.is_successful, logs.cached, logs.error,
+    requests.id AS request_id, requests.provider, requests.req_type, requests.from_lang, requests.to_lang,
+    requests.user_id, users.email, requests.organization_id
+FROM logs
+JOIN requests ON logs.request_id = requests.id
+LEFT JOIN users ON requests.user_id = users.id
+WHERE logs.id=$1
+`
+
+type GetLogRow struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	IsSuccessful   bool
+	Cached         bool
+	Error          sql.NullString
+	RequestID      uuid.UUID
+	Provider       string
+	ReqType        string
+	FromLang       string
+	ToLang         string
+	UserID         uuid.NullUUID
+	Email          sql.NullString
+	OrganizationID uuid.NullUUID
+}
+
+func (q *Queries) GetLog(ctx context.Context, id uuid.UUID) (GetLogRow, error) {
+	row := q.db.QueryRowContext(ctx, getLog, id)
+	var i GetLogRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.IsSuccessful,
+		&i.Cached,
+		&i.Error,
+		&i.RequestID,
+		&i.Provider,
+		&i.ReqType,
+		&i.FromLang,
+		&i.ToLang,
+		&i.UserID,
+		&i.Email,
+		&i.OrganizationID,
+	)
+	return i, err
+}
+
+const getLogs = `-- name: GetLogs :many
+SELECT logs.id, logs.created_at, logs.is_successful, logs.cached, logs.error,
+    requests.id AS request_id, requests.provider, requests.req_type, requests.from_lang, requests.to_lang,
+    requests.user_id, users.email, requests.organization_id
+FROM logs
+JOIN requests ON logs.request_id = requests.id
+LEFT JOIN users ON requests.user_id = users.id
+WHERE ($1::uuid IS NULL OR requests.user_id = $1)
+    AND ($2::uuid IS NULL OR requests.organization_id = $2)
+    AND ($3::text IS NULL OR requests.provider = $3)
+    AND ($4::text IS NULL OR requests.from_lang = $4)
+    AND ($5::text IS NULL OR requests.to_lang = $5)
+    AND ($6::timestamp IS NULL OR logs.created_at >= $6)
+    AND ($7::timestamp IS NULL OR logs.created_at < $7)
+    AND ($8::boolean IS NULL OR logs.is_successful = $8)
+    AND ($9::boolean IS NULL OR logs.cached = $9)
+ORDER BY logs.created_at DESC
+LIMIT $11 OFFSET $10
+`
+
+type GetLogsParams struct {
+	UserID         uuid.NullUUID
+	OrganizationID uuid.NullUUID
+	Provider       sql.NullString
+	FromLang       sql.NullString
+	ToLang         sql.NullString
+	CreatedAfter   sql.NullTime
+	CreatedBefore  sql.NullTime
+	IsSuccessful   sql.NullBool
+	Cached         sql.NullBool
+	Offset         int32
+	Limit          int32
+}
+
+type GetLogsRow struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	IsSuccessful   bool
+	Cached         bool
+	Error          sql.NullString
+	RequestID      uuid.UUID
+	Provider       string
+	ReqType        string
+	FromLang       string
+	ToLang         string
+	UserID         uuid.NullUUID
+	Email          sql.NullString
+	OrganizationID uuid.NullUUID
+}
+
+func (q *Queries) GetLogs(ctx context.Context, arg GetLogsParams) ([]GetLogsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getLogs,
+		arg.UserID,
+		arg.OrganizationID,
+		arg.Provider,
+		arg.FromLang,
+		arg.ToLang,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.IsSuccessful,
+		arg.Cached,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetLogsRow
+	for rows.Next() {
+		var i GetLogsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.IsSuccessful,
+			&i.Cached,
+			&i.Error,
+			&i.RequestID,
+			&i.Provider,
+			&i.ReqType,
+			&i.FromLang,
+			&i.ToLang,
+			&i.UserID,
+			&i.Email,
+			&i.OrganizationID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
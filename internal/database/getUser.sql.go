@@ -12,7 +12,7 @@ import (
 )
 
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, email, is_admin, hashed_password
+SELECT id, created_at, updated_at, email, hashed_password, requests_per_minute
 FROM users
 WHERE id=$1
 `
@@ -25,8 +25,8 @@ func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Email,
-		&i.IsAdmin,
 		&i.HashedPassword,
+		&i.RequestsPerMinute,
 	)
 	return i, err
 }
@@ -10,7 +10,7 @@ import (
 )
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, is_admin, hashed_password
+SELECT id, created_at, updated_at, email, hashed_password, requests_per_minute
 FROM users
 WHERE email=$1
 `
@@ -23,8 +23,8 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Email,
-		&i.IsAdmin,
 		&i.HashedPassword,
+		&i.RequestsPerMinute,
 	)
 	return i, err
 }
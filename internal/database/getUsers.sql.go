@@ -10,7 +10,7 @@ import (
 )
 
 const getUsers = `-- name: GetUsers :many
-SELECT id, created_at, updated_at, email, is_admin, hashed_password
+SELECT id, created_at, updated_at, email, hashed_password, requests_per_minute
 FROM users
 ORDER BY created_at DESC
 LIMIT $1 OFFSET $2
@@ -35,8 +35,8 @@ func (q *Queries) GetUsers(ctx context.Context, arg GetUsersParams) ([]User, err
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Email,
-			&i.IsAdmin,
 			&i.HashedPassword,
+			&i.RequestsPerMinute,
 		); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,273 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: glossaries.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createGlossary = `-- name: CreateGlossary :one
+INSERT INTO glossaries (id, created_at, updated_at, name,source_lang,target_lang,shared,user_id,organization_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING id, created_at, updated_at, name, source_lang, target_lang, shared, user_id, organization_id, deepl_glossary_id, deepl_glossary_version
+`
+
+type CreateGlossaryParams struct {
+	Name           string
+	SourceLang     string
+	TargetLang     string
+	Shared         bool
+	UserID         uuid.UUID
+	OrganizationID uuid.NullUUID
+}
+
+func (q *Queries) CreateGlossary(ctx context.Context, arg CreateGlossaryParams) (Glossary, error) {
+	row := q.db.QueryRowContext(ctx, createGlossary,
+		arg.Name,
+		arg.SourceLang,
+		arg.TargetLang,
+		arg.Shared,
+		arg.UserID,
+		arg.OrganizationID,
+	)
+	var i Glossary
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.SourceLang,
+		&i.TargetLang,
+		&i.Shared,
+		&i.UserID,
+		&i.OrganizationID,
+		&i.DeeplGlossaryID,
+		&i.DeeplGlossaryVersion,
+	)
+	return i, err
+}
+
+const deleteGlossary = `-- name: DeleteGlossary :exec
+DELETE FROM glossaries
+WHERE id=$1
+`
+
+func (q *Queries) DeleteGlossary(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteGlossary, id)
+	return err
+}
+
+const getGlossaries = `-- name: GetGlossaries :many
+SELECT id, created_at, updated_at, name, source_lang, target_lang, shared, user_id, organization_id, deepl_glossary_id, deepl_glossary_version
+FROM glossaries
+WHERE user_id = $1 OR shared = true
+    OR (organization_id IS NOT NULL AND organization_id = $2)
+ORDER BY created_at DESC
+LIMIT $4 OFFSET $3
+`
+
+type GetGlossariesParams struct {
+	UserID         uuid.UUID
+	OrganizationID uuid.NullUUID
+	Offset         int32
+	Limit          int32
+}
+
+// glossaries a user can use, their own, their organization's and shared ones
+func (q *Queries) GetGlossaries(ctx context.Context, arg GetGlossariesParams) ([]Glossary, error) {
+	rows, err := q.db.QueryContext(ctx, getGlossaries,
+		arg.UserID,
+		arg.OrganizationID,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Glossary
+	for rows.Next() {
+		var i Glossary
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.SourceLang,
+			&i.TargetLang,
+			&i.Shared,
+			&i.UserID,
+			&i.OrganizationID,
+			&i.DeeplGlossaryID,
+			&i.DeeplGlossaryVersion,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getGlossary = `-- name: GetGlossary :one
+SELECT id, created_at, updated_at, name, source_lang, target_lang, shared, user_id, organization_id, deepl_glossary_id, deepl_glossary_version
+FROM glossaries
+WHERE id=$1
+`
+
+func (q *Queries) GetGlossary(ctx context.Context, id uuid.UUID) (Glossary, error) {
+	row := q.db.QueryRowContext(ctx, getGlossary, id)
+	var i Glossary
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.SourceLang,
+		&i.TargetLang,
+		&i.Shared,
+		&i.UserID,
+		&i.OrganizationID,
+		&i.DeeplGlossaryID,
+		&i.DeeplGlossaryVersion,
+	)
+	return i, err
+}
+
+const getGlossaryEntries = `-- name: GetGlossaryEntries :many
+SELECT id, source, target, glossary_id
+FROM glossary_entries
+WHERE glossary_id=$1
+ORDER BY source
+`
+
+func (q *Queries) GetGlossaryEntries(ctx context.Context, glossaryID uuid.UUID) ([]GlossaryEntry, error) {
+	rows, err := q.db.QueryContext(ctx, getGlossaryEntries, glossaryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GlossaryEntry
+	for rows.Next() {
+		var i GlossaryEntry
+		if err := rows.Scan(
+			&i.ID,
+			&i.Source,
+			&i.Target,
+			&i.GlossaryID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const replaceGlossaryEntries = `-- name: ReplaceGlossaryEntries :exec
+WITH deleted AS (
+    DELETE FROM glossary_entries
+    WHERE glossary_id = $3
+)
+INSERT INTO glossary_entries (id, source, target, glossary_id)
+SELECT gen_random_uuid(), unnest($1::text[]), unnest($2::text[]), $3::uuid
+`
+
+type ReplaceGlossaryEntriesParams struct {
+	Sources    []string
+	Targets    []string
+	GlossaryID uuid.UUID
+}
+
+// a single statement so the old entries are never gone without the new ones
+func (q *Queries) ReplaceGlossaryEntries(ctx context.Context, arg ReplaceGlossaryEntriesParams) error {
+	_, err := q.db.ExecContext(ctx, replaceGlossaryEntries, pq.Array(arg.Sources), pq.Array(arg.Targets), arg.GlossaryID)
+	return err
+}
+
+const setDeepLGlossary = `-- name: SetDeepLGlossary :execrows
+UPDATE glossaries
+SET deepl_glossary_id = $1, deepl_glossary_version = $2
+WHERE id = $3 AND deepl_glossary_id IS NOT DISTINCT FROM $4
+`
+
+type SetDeepLGlossaryParams struct {
+	DeeplGlossaryID      sql.NullString
+	DeeplGlossaryVersion sql.NullString
+	ID                   uuid.UUID
+	PreviousID           sql.NullString
+}
+
+// only replaces the copy the caller saw, so of two servers copying the glossary at once one
+// wins and the other knows to delete its copy. updated_at is left alone, it is the version
+func (q *Queries) SetDeepLGlossary(ctx context.Context, arg SetDeepLGlossaryParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setDeepLGlossary,
+		arg.DeeplGlossaryID,
+		arg.DeeplGlossaryVersion,
+		arg.ID,
+		arg.PreviousID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateGlossary = `-- name: UpdateGlossary :one
+UPDATE glossaries
+SET name = $2 , shared = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, source_lang, target_lang, shared, user_id, organization_id, deepl_glossary_id, deepl_glossary_version
+`
+
+type UpdateGlossaryParams struct {
+	ID     uuid.UUID
+	Name   string
+	Shared bool
+}
+
+func (q *Queries) UpdateGlossary(ctx context.Context, arg UpdateGlossaryParams) (Glossary, error) {
+	row := q.db.QueryRowContext(ctx, updateGlossary, arg.ID, arg.Name, arg.Shared)
+	var i Glossary
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.SourceLang,
+		&i.TargetLang,
+		&i.Shared,
+		&i.UserID,
+		&i.OrganizationID,
+		&i.DeeplGlossaryID,
+		&i.DeeplGlossaryVersion,
+	)
+	return i, err
+}
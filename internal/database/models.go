@@ -11,6 +11,63 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     []string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	UserID     uuid.UUID
+}
+
+type AuditEvent struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Action    string
+	Target    string
+	Ip        string
+	Details   sql.NullString
+	ActorID   uuid.NullUUID
+}
+
+type Document struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Provider  string
+	FileName  string
+	FromLang  string
+	ToLang    string
+	Status    string
+	Error     sql.NullString
+	UserID    uuid.UUID
+}
+
+type Glossary struct {
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	Name                 string
+	SourceLang           string
+	TargetLang           string
+	Shared               bool
+	UserID               uuid.UUID
+	OrganizationID       uuid.NullUUID
+	DeeplGlossaryID      sql.NullString
+	DeeplGlossaryVersion sql.NullString
+}
+
+type GlossaryEntry struct {
+	ID         uuid.UUID
+	Source     string
+	Target     string
+	GlossaryID uuid.UUID
+}
+
 type Log struct {
 	ID           uuid.UUID
 	CreatedAt    time.Time
@@ -21,22 +78,90 @@ type Log struct {
 	RequestID    uuid.UUID
 }
 
-type Request struct {
+type Organization struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Name             string
+	MonthlyCharLimit sql.NullInt64
+	MonthlyByteLimit sql.NullInt64
+}
+
+type OrganizationMember struct {
+	UserID         uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	IsAdmin        bool
+	OrganizationID uuid.UUID
+}
+
+type Quota struct {
+	UserID           uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	MonthlyCharLimit sql.NullInt64
+	MonthlyByteLimit sql.NullInt64
+}
+
+type RefreshToken struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	Provider  string
-	ReqType   string
-	FromLang  string
-	ToLang    string
+	TokenHash string
+	ExpiresAt time.Time
+	RevokedAt sql.NullTime
 	UserID    uuid.UUID
 }
 
-type User struct {
+type Request struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
-	Email          string
-	IsAdmin        bool
-	HashedPassword sql.NullString
+	Provider       string
+	ReqType        string
+	FromLang       string
+	ToLang         string
+	UserID         uuid.NullUUID
+	CharCount      int64
+	ByteCount      int64
+	OrganizationID uuid.NullUUID
+}
+
+type Role struct {
+	Name              string
+	Description       string
+	RequestsPerMinute sql.NullInt32
+}
+
+type RolePermission struct {
+	Role       string
+	Permission string
+}
+
+type TmEntry struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	SourceLang string
+	TargetLang string
+	Source     string
+	Target     string
+	Origin     string
+	Status     string
+	SourceHash sql.NullString
+}
+
+type User struct {
+	ID                uuid.UUID
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Email             string
+	HashedPassword    sql.NullString
+	RequestsPerMinute sql.NullInt32
+}
+
+type UserRole struct {
+	UserID    uuid.UUID
+	Role      string
+	CreatedAt time.Time
 }
@@ -0,0 +1,272 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: organizations.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const addOrganizationMember = `-- name: AddOrganizationMember :one
+INSERT INTO organization_members (user_id, created_at, updated_at, is_admin, organization_id)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3
+)
+RETURNING user_id, created_at, updated_at, is_admin, organization_id
+`
+
+type AddOrganizationMemberParams struct {
+	UserID         uuid.UUID
+	IsAdmin        bool
+	OrganizationID uuid.UUID
+}
+
+func (q *Queries) AddOrganizationMember(ctx context.Context, arg AddOrganizationMemberParams) (OrganizationMember, error) {
+	row := q.db.QueryRowContext(ctx, addOrganizationMember, arg.UserID, arg.IsAdmin, arg.OrganizationID)
+	var i OrganizationMember
+	err := row.Scan(
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsAdmin,
+		&i.OrganizationID,
+	)
+	return i, err
+}
+
+const createOrganization = `-- name: CreateOrganization :one
+INSERT INTO organizations (id, created_at, updated_at, name)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1
+)
+RETURNING id, created_at, updated_at, name, monthly_char_limit, monthly_byte_limit
+`
+
+func (q *Queries) CreateOrganization(ctx context.Context, name string) (Organization, error) {
+	row := q.db.QueryRowContext(ctx, createOrganization, name)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.MonthlyCharLimit,
+		&i.MonthlyByteLimit,
+	)
+	return i, err
+}
+
+const deleteOrganization = `-- name: DeleteOrganization :exec
+DELETE FROM organizations
+WHERE id=$1
+`
+
+func (q *Queries) DeleteOrganization(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteOrganization, id)
+	return err
+}
+
+const getMembership = `-- name: GetMembership :one
+SELECT user_id, created_at, updated_at, is_admin, organization_id
+FROM organization_members
+WHERE user_id=$1
+`
+
+func (q *Queries) GetMembership(ctx context.Context, userID uuid.UUID) (OrganizationMember, error) {
+	row := q.db.QueryRowContext(ctx, getMembership, userID)
+	var i OrganizationMember
+	err := row.Scan(
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsAdmin,
+		&i.OrganizationID,
+	)
+	return i, err
+}
+
+const getOrganization = `-- name: GetOrganization :one
+SELECT id, created_at, updated_at, name, monthly_char_limit, monthly_byte_limit
+FROM organizations
+WHERE id=$1
+`
+
+func (q *Queries) GetOrganization(ctx context.Context, id uuid.UUID) (Organization, error) {
+	row := q.db.QueryRowContext(ctx, getOrganization, id)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.MonthlyCharLimit,
+		&i.MonthlyByteLimit,
+	)
+	return i, err
+}
+
+const getOrganizationMembers = `-- name: GetOrganizationMembers :many
+SELECT organization_members.user_id, users.email, organization_members.is_admin, organization_members.created_at
+FROM organization_members
+JOIN users ON users.id = organization_members.user_id
+WHERE organization_members.organization_id = $1
+ORDER BY users.email
+`
+
+type GetOrganizationMembersRow struct {
+	UserID    uuid.UUID
+	Email     string
+	IsAdmin   bool
+	CreatedAt time.Time
+}
+
+func (q *Queries) GetOrganizationMembers(ctx context.Context, organizationID uuid.UUID) ([]GetOrganizationMembersRow, error) {
+	rows, err := q.db.QueryContext(ctx, getOrganizationMembers, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetOrganizationMembersRow
+	for rows.Next() {
+		var i GetOrganizationMembersRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Email,
+			&i.IsAdmin,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getOrganizations = `-- name: GetOrganizations :many
+SELECT id, created_at, updated_at, name, monthly_char_limit, monthly_byte_limit
+FROM organizations
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2
+`
+
+type GetOrganizationsParams struct {
+	Limit  int32
+	Offset int32
+}
+
+func (q *Queries) GetOrganizations(ctx context.Context, arg GetOrganizationsParams) ([]Organization, error) {
+	rows, err := q.db.QueryContext(ctx, getOrganizations, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Organization
+	for rows.Next() {
+		var i Organization
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.MonthlyCharLimit,
+			&i.MonthlyByteLimit,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const removeOrganizationMember = `-- name: RemoveOrganizationMember :exec
+DELETE FROM organization_members
+WHERE organization_id = $1 AND user_id = $2
+`
+
+type RemoveOrganizationMemberParams struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) RemoveOrganizationMember(ctx context.Context, arg RemoveOrganizationMemberParams) error {
+	_, err := q.db.ExecContext(ctx, removeOrganizationMember, arg.OrganizationID, arg.UserID)
+	return err
+}
+
+const setOrganizationMemberAdmin = `-- name: SetOrganizationMemberAdmin :one
+UPDATE organization_members
+SET is_admin = $3, updated_at = NOW()
+WHERE organization_id = $1 AND user_id = $2
+RETURNING user_id, created_at, updated_at, is_admin, organization_id
+`
+
+type SetOrganizationMemberAdminParams struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	IsAdmin        bool
+}
+
+func (q *Queries) SetOrganizationMemberAdmin(ctx context.Context, arg SetOrganizationMemberAdminParams) (OrganizationMember, error) {
+	row := q.db.QueryRowContext(ctx, setOrganizationMemberAdmin, arg.OrganizationID, arg.UserID, arg.IsAdmin)
+	var i OrganizationMember
+	err := row.Scan(
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsAdmin,
+		&i.OrganizationID,
+	)
+	return i, err
+}
+
+const setOrganizationQuota = `-- name: SetOrganizationQuota :one
+UPDATE organizations
+SET monthly_char_limit = $2, monthly_byte_limit = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, monthly_char_limit, monthly_byte_limit
+`
+
+type SetOrganizationQuotaParams struct {
+	ID               uuid.UUID
+	MonthlyCharLimit sql.NullInt64
+	MonthlyByteLimit sql.NullInt64
+}
+
+func (q *Queries) SetOrganizationQuota(ctx context.Context, arg SetOrganizationQuotaParams) (Organization, error) {
+	row := q.db.QueryRowContext(ctx, setOrganizationQuota, arg.ID, arg.MonthlyCharLimit, arg.MonthlyByteLimit)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.MonthlyCharLimit,
+		&i.MonthlyByteLimit,
+	)
+	return i, err
+}
@@ -0,0 +1,179 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: quotas.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const getOrganizationUsage = `-- name: GetOrganizationUsage :one
+SELECT COALESCE(SUM(requests.char_count), 0)::bigint AS chars_used,
+    COALESCE(SUM(requests.byte_count), 0)::bigint AS bytes_used
+FROM requests
+JOIN logs ON logs.request_id = requests.id
+WHERE requests.organization_id = $1::uuid
+    AND requests.created_at >= $2
+    AND logs.cached = false
+    AND logs.is_successful = true
+`
+
+type GetOrganizationUsageParams struct {
+	OrganizationID uuid.UUID
+	Since          time.Time
+}
+
+type GetOrganizationUsageRow struct {
+	CharsUsed int64
+	BytesUsed int64
+}
+
+// usage of everything translated while a member of the organization
+func (q *Queries) GetOrganizationUsage(ctx context.Context, arg GetOrganizationUsageParams) (GetOrganizationUsageRow, error) {
+	row := q.db.QueryRowContext(ctx, getOrganizationUsage, arg.OrganizationID, arg.Since)
+	var i GetOrganizationUsageRow
+	err := row.Scan(&i.CharsUsed, &i.BytesUsed)
+	return i, err
+}
+
+const getOrganizationUsageByUser = `-- name: GetOrganizationUsageByUser :many
+SELECT requests.user_id, users.email,
+    COALESCE(SUM(requests.char_count), 0)::bigint AS chars_used,
+    COALESCE(SUM(requests.byte_count), 0)::bigint AS bytes_used
+FROM requests
+JOIN logs ON logs.request_id = requests.id
+LEFT JOIN users ON users.id = requests.user_id
+WHERE requests.organization_id = $1::uuid
+    AND requests.created_at >= $2
+    AND logs.cached = false
+    AND logs.is_successful = true
+GROUP BY requests.user_id, users.email
+ORDER BY users.email
+`
+
+type GetOrganizationUsageByUserParams struct {
+	OrganizationID uuid.UUID
+	Since          time.Time
+}
+
+type GetOrganizationUsageByUserRow struct {
+	UserID    uuid.NullUUID
+	Email     sql.NullString
+	CharsUsed int64
+	BytesUsed int64
+}
+
+func (q *Queries) GetOrganizationUsageByUser(ctx context.Context, arg GetOrganizationUsageByUserParams) ([]GetOrganizationUsageByUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getOrganizationUsageByUser, arg.OrganizationID, arg.Since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetOrganizationUsageByUserRow
+	for rows.Next() {
+		var i GetOrganizationUsageByUserRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Email,
+			&i.CharsUsed,
+			&i.BytesUsed,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getQuota = `-- name: GetQuota :one
+SELECT user_id, created_at, updated_at, monthly_char_limit, monthly_byte_limit
+FROM quotas
+WHERE user_id=$1
+`
+
+func (q *Queries) GetQuota(ctx context.Context, userID uuid.UUID) (Quota, error) {
+	row := q.db.QueryRowContext(ctx, getQuota, userID)
+	var i Quota
+	err := row.Scan(
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.MonthlyCharLimit,
+		&i.MonthlyByteLimit,
+	)
+	return i, err
+}
+
+const getUsage = `-- name: GetUsage :one
+SELECT COALESCE(SUM(requests.char_count), 0)::bigint AS chars_used,
+    COALESCE(SUM(requests.byte_count), 0)::bigint AS bytes_used
+FROM requests
+JOIN logs ON logs.request_id = requests.id
+WHERE requests.user_id = $1::uuid
+    AND requests.created_at >= $2
+    AND logs.cached = false
+    AND logs.is_successful = true
+`
+
+type GetUsageParams struct {
+	UserID uuid.UUID
+	Since  time.Time
+}
+
+type GetUsageRow struct {
+	CharsUsed int64
+	BytesUsed int64
+}
+
+// only translations that actually reached the provider count, cache hits and failures are free
+func (q *Queries) GetUsage(ctx context.Context, arg GetUsageParams) (GetUsageRow, error) {
+	row := q.db.QueryRowContext(ctx, getUsage, arg.UserID, arg.Since)
+	var i GetUsageRow
+	err := row.Scan(&i.CharsUsed, &i.BytesUsed)
+	return i, err
+}
+
+const setQuota = `-- name: SetQuota :one
+INSERT INTO quotas (user_id, created_at, updated_at, monthly_char_limit, monthly_byte_limit)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3
+)
+ON CONFLICT (user_id) DO UPDATE
+SET monthly_char_limit = EXCLUDED.monthly_char_limit, monthly_byte_limit = EXCLUDED.monthly_byte_limit, updated_at = NOW()
+RETURNING user_id, created_at, updated_at, monthly_char_limit, monthly_byte_limit
+`
+
+type SetQuotaParams struct {
+	UserID           uuid.UUID
+	MonthlyCharLimit sql.NullInt64
+	MonthlyByteLimit sql.NullInt64
+}
+
+func (q *Queries) SetQuota(ctx context.Context, arg SetQuotaParams) (Quota, error) {
+	row := q.db.QueryRowContext(ctx, setQuota, arg.UserID, arg.MonthlyCharLimit, arg.MonthlyByteLimit)
+	var i Quota
+	err := row.Scan(
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.MonthlyCharLimit,
+		&i.MonthlyByteLimit,
+	)
+	return i, err
+}
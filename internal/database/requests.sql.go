@@ -12,7 +12,7 @@ import (
 )
 
 const createRequest = `-- name: CreateRequest :one
-INSERT INTO requests (id, created_at, updated_at, provider,req_type,from_lang,to_lang,user_id)
+INSERT INTO requests (id, created_at, updated_at, provider,req_type,from_lang,to_lang,user_id,char_count,byte_count,organization_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
@@ -21,25 +21,33 @@ VALUES (
     $2,
     $3,
     $4,
-    $5
+    $7::uuid,
+    $5,
+    $6,
+    (SELECT organization_id FROM organization_members WHERE organization_members.user_id = $7::uuid)
 )
-RETURNING id, created_at, updated_at, provider, req_type, from_lang, to_lang, user_id
+RETURNING id, created_at, updated_at, provider, req_type, from_lang, to_lang, user_id, char_count, byte_count, organization_id
 `
 
 type CreateRequestParams struct {
-	Provider string
-	ReqType  string
-	FromLang string
-	ToLang   string
-	UserID   uuid.UUID
+	Provider  string
+	ReqType   string
+	FromLang  string
+	ToLang    string
+	CharCount int64
+	ByteCount int64
+	UserID    uuid.UUID
 }
 
+// requests are attributed to the organization the user is in when they are made
 func (q *Queries) CreateRequest(ctx context.Context, arg CreateRequestParams) (Request, error) {
 	row := q.db.QueryRowContext(ctx, createRequest,
 		arg.Provider,
 		arg.ReqType,
 		arg.FromLang,
 		arg.ToLang,
+		arg.CharCount,
+		arg.ByteCount,
 		arg.UserID,
 	)
 	var i Request
@@ -52,6 +60,9 @@ func (q *Queries) CreateRequest(ctx context.Context, arg CreateRequestParams) (R
 		&i.FromLang,
 		&i.ToLang,
 		&i.UserID,
+		&i.CharCount,
+		&i.ByteCount,
+		&i.OrganizationID,
 	)
 	return i, err
 }
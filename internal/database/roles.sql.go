@@ -0,0 +1,191 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: roles.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const getRoleRateLimit = `-- name: GetRoleRateLimit :one
+SELECT COALESCE(MAX(roles.requests_per_minute), 0)::integer AS requests_per_minute
+FROM user_roles
+JOIN roles ON roles.name = user_roles.role
+WHERE user_roles.user_id = $1
+`
+
+// the highest limit of the user's roles, 0 when none of them has one
+func (q *Queries) GetRoleRateLimit(ctx context.Context, userID uuid.UUID) (int32, error) {
+	row := q.db.QueryRowContext(ctx, getRoleRateLimit, userID)
+	var requests_per_minute int32
+	err := row.Scan(&requests_per_minute)
+	return requests_per_minute, err
+}
+
+const getRoles = `-- name: GetRoles :many
+SELECT roles.name, roles.description, roles.requests_per_minute,
+    COALESCE(array_agg(role_permissions.permission ORDER BY role_permissions.permission)
+        FILTER (WHERE role_permissions.permission IS NOT NULL), '{}')::text[] AS permissions
+FROM roles
+LEFT JOIN role_permissions ON role_permissions.role = roles.name
+GROUP BY roles.name
+ORDER BY roles.name
+`
+
+type GetRolesRow struct {
+	Name              string
+	Description       string
+	RequestsPerMinute sql.NullInt32
+	Permissions       []string
+}
+
+func (q *Queries) GetRoles(ctx context.Context) ([]GetRolesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRoles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRolesRow
+	for rows.Next() {
+		var i GetRolesRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.Description,
+			&i.RequestsPerMinute,
+			pq.Array(&i.Permissions),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserPermissions = `-- name: GetUserPermissions :many
+SELECT DISTINCT role_permissions.permission
+FROM user_roles
+JOIN role_permissions ON role_permissions.role = user_roles.role
+WHERE user_roles.user_id = $1
+ORDER BY role_permissions.permission
+`
+
+func (q *Queries) GetUserPermissions(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getUserPermissions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		items = append(items, permission)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserRoles = `-- name: GetUserRoles :many
+SELECT role
+FROM user_roles
+WHERE user_id = $1
+ORDER BY role
+`
+
+func (q *Queries) GetUserRoles(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getUserRoles, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		items = append(items, role)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUsersRoles = `-- name: GetUsersRoles :many
+SELECT user_id, role
+FROM user_roles
+WHERE user_id = ANY($1::uuid[])
+ORDER BY role
+`
+
+type GetUsersRolesRow struct {
+	UserID uuid.UUID
+	Role   string
+}
+
+func (q *Queries) GetUsersRoles(ctx context.Context, userIds []uuid.UUID) ([]GetUsersRolesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersRoles, pq.Array(userIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUsersRolesRow
+	for rows.Next() {
+		var i GetUsersRolesRow
+		if err := rows.Scan(&i.UserID, &i.Role); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setUserRoles = `-- name: SetUserRoles :exec
+WITH deleted AS (
+    DELETE FROM user_roles
+    WHERE user_id = $1 AND role <> ALL($2::text[])
+)
+INSERT INTO user_roles (user_id, role, created_at)
+SELECT $1, unnest($2::text[]), NOW()
+ON CONFLICT DO NOTHING
+`
+
+type SetUserRolesParams struct {
+	UserID uuid.UUID
+	Roles  []string
+}
+
+// removed and added roles are disjoint, so this can run as one statement
+func (q *Queries) SetUserRoles(ctx context.Context, arg SetUserRolesParams) error {
+	_, err := q.db.ExecContext(ctx, setUserRoles, arg.UserID, pq.Array(arg.Roles))
+	return err
+}
@@ -0,0 +1,306 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: translationMemory.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const getTMEntries = `-- name: GetTMEntries :many
+SELECT id, created_at, updated_at, source_lang, target_lang, source, target, origin, status, source_hash
+FROM tm_entries
+WHERE ($1::text IS NULL OR source_lang = $1)
+    AND ($2::text IS NULL OR target_lang = $2)
+    AND ($3::text IS NULL OR status = $3)
+ORDER BY created_at DESC, id
+LIMIT $5 OFFSET $4
+`
+
+type GetTMEntriesParams struct {
+	SourceLang sql.NullString
+	TargetLang sql.NullString
+	Status     sql.NullString
+	Offset     int32
+	Limit      int32
+}
+
+func (q *Queries) GetTMEntries(ctx context.Context, arg GetTMEntriesParams) ([]TmEntry, error) {
+	rows, err := q.db.QueryContext(ctx, getTMEntries,
+		arg.SourceLang,
+		arg.TargetLang,
+		arg.Status,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TmEntry
+	for rows.Next() {
+		var i TmEntry
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SourceLang,
+			&i.TargetLang,
+			&i.Source,
+			&i.Target,
+			&i.Origin,
+			&i.Status,
+			&i.SourceHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTMEntry = `-- name: GetTMEntry :one
+SELECT id, created_at, updated_at, source_lang, target_lang, source, target, origin, status, source_hash
+FROM tm_entries
+WHERE id = $1
+`
+
+func (q *Queries) GetTMEntry(ctx context.Context, id uuid.UUID) (TmEntry, error) {
+	row := q.db.QueryRowContext(ctx, getTMEntry, id)
+	var i TmEntry
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.SourceLang,
+		&i.TargetLang,
+		&i.Source,
+		&i.Target,
+		&i.Origin,
+		&i.Status,
+		&i.SourceHash,
+	)
+	return i, err
+}
+
+const getTMExactMatches = `-- name: GetTMExactMatches :many
+SELECT id, created_at, updated_at, source_lang, target_lang, source, target, origin, status, source_hash
+FROM tm_entries
+WHERE source_lang = $1 AND target_lang = $2 AND status = 'approved'
+    AND source_hash = ANY(
+        SELECT md5(s) FROM unnest($3::text[]) AS s
+    )
+    AND source = ANY($3::text[])
+`
+
+type GetTMExactMatchesParams struct {
+	SourceLang string
+	TargetLang string
+	Sources    []string
+}
+
+// approved translations of any of the sources
+func (q *Queries) GetTMExactMatches(ctx context.Context, arg GetTMExactMatchesParams) ([]TmEntry, error) {
+	rows, err := q.db.QueryContext(ctx, getTMExactMatches, arg.SourceLang, arg.TargetLang, pq.Array(arg.Sources))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TmEntry
+	for rows.Next() {
+		var i TmEntry
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SourceLang,
+			&i.TargetLang,
+			&i.Source,
+			&i.Target,
+			&i.Origin,
+			&i.Status,
+			&i.SourceHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTMFuzzyMatches = `-- name: GetTMFuzzyMatches :many
+SELECT tm_entries.id, tm_entries.created_at, tm_entries.updated_at, tm_entries.source_lang, tm_entries.target_lang, tm_entries.source, tm_entries.target, tm_entries.origin, tm_entries.status, tm_entries.source_hash, similarity(source, $1::text)::real AS score
+FROM tm_entries
+WHERE source_lang = $2 AND target_lang = $3 AND status = 'approved'
+    AND source % $1::text
+ORDER BY score DESC
+LIMIT $4
+`
+
+type GetTMFuzzyMatchesParams struct {
+	Text       string
+	SourceLang string
+	TargetLang string
+	Limit      int32
+}
+
+type GetTMFuzzyMatchesRow struct {
+	TmEntry TmEntry
+	Score   float32
+}
+
+// approved entries at least as similar to the text as SetTMThreshold asks, the closest first
+func (q *Queries) GetTMFuzzyMatches(ctx context.Context, arg GetTMFuzzyMatchesParams) ([]GetTMFuzzyMatchesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTMFuzzyMatches,
+		arg.Text,
+		arg.SourceLang,
+		arg.TargetLang,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTMFuzzyMatchesRow
+	for rows.Next() {
+		var i GetTMFuzzyMatchesRow
+		if err := rows.Scan(
+			&i.TmEntry.ID,
+			&i.TmEntry.CreatedAt,
+			&i.TmEntry.UpdatedAt,
+			&i.TmEntry.SourceLang,
+			&i.TmEntry.TargetLang,
+			&i.TmEntry.Source,
+			&i.TmEntry.Target,
+			&i.TmEntry.Origin,
+			&i.TmEntry.Status,
+			&i.TmEntry.SourceHash,
+			&i.Score,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const importTMEntries = `-- name: ImportTMEntries :execrows
+INSERT INTO tm_entries (id, created_at, updated_at, source_lang, target_lang, source, target, origin, status)
+SELECT gen_random_uuid(), NOW(), NOW(), unnest($1::text[]), unnest($2::text[]), unnest($3::text[]), unnest($4::text[]), 'import', $5
+ON CONFLICT (source_lang, target_lang, source_hash) DO UPDATE
+SET target = excluded.target, origin = excluded.origin, status = excluded.status, updated_at = NOW()
+`
+
+type ImportTMEntriesParams struct {
+	SourceLangs []string
+	TargetLangs []string
+	Sources     []string
+	Targets     []string
+	Status      string
+}
+
+// imports replace the translation and status of existing entries
+func (q *Queries) ImportTMEntries(ctx context.Context, arg ImportTMEntriesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, importTMEntries,
+		pq.Array(arg.SourceLangs),
+		pq.Array(arg.TargetLangs),
+		pq.Array(arg.Sources),
+		pq.Array(arg.Targets),
+		arg.Status,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const recordTMEntries = `-- name: RecordTMEntries :exec
+INSERT INTO tm_entries (id, created_at, updated_at, source_lang, target_lang, source, target, origin, status)
+SELECT gen_random_uuid(), NOW(), NOW(), $1, $2, unnest($3::text[]), unnest($4::text[]), $5, 'pending'
+ON CONFLICT (source_lang, target_lang, source_hash) DO NOTHING
+`
+
+type RecordTMEntriesParams struct {
+	SourceLang string
+	TargetLang string
+	Sources    []string
+	Targets    []string
+	Origin     string
+}
+
+// machine translations start out pending, entries that already exist are left alone
+func (q *Queries) RecordTMEntries(ctx context.Context, arg RecordTMEntriesParams) error {
+	_, err := q.db.ExecContext(ctx, recordTMEntries,
+		arg.SourceLang,
+		arg.TargetLang,
+		pq.Array(arg.Sources),
+		pq.Array(arg.Targets),
+		arg.Origin,
+	)
+	return err
+}
+
+const setTMThreshold = `-- name: SetTMThreshold :exec
+SELECT set_config('pg_trgm.similarity_threshold', $1::text, true)
+`
+
+// the similarity the % operator asks for, until the end of the transaction
+func (q *Queries) SetTMThreshold(ctx context.Context, threshold string) error {
+	_, err := q.db.ExecContext(ctx, setTMThreshold, threshold)
+	return err
+}
+
+const updateTMEntry = `-- name: UpdateTMEntry :one
+UPDATE tm_entries
+SET target = $2, status = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, source_lang, target_lang, source, target, origin, status, source_hash
+`
+
+type UpdateTMEntryParams struct {
+	ID     uuid.UUID
+	Target string
+	Status string
+}
+
+func (q *Queries) UpdateTMEntry(ctx context.Context, arg UpdateTMEntryParams) (TmEntry, error) {
+	row := q.db.QueryRowContext(ctx, updateTMEntry, arg.ID, arg.Target, arg.Status)
+	var i TmEntry
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.SourceLang,
+		&i.TargetLang,
+		&i.Source,
+		&i.Target,
+		&i.Origin,
+		&i.Status,
+		&i.SourceHash,
+	)
+	return i, err
+}
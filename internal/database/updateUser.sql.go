@@ -12,35 +12,71 @@ import (
 	"github.com/google/uuid"
 )
 
+const setUserRateLimit = `-- name: SetUserRateLimit :one
+UPDATE users
+SET requests_per_minute = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, requests_per_minute
+`
+
+type SetUserRateLimitParams struct {
+	ID                uuid.UUID
+	RequestsPerMinute sql.NullInt32
+}
+
+func (q *Queries) SetUserRateLimit(ctx context.Context, arg SetUserRateLimitParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRateLimit, arg.ID, arg.RequestsPerMinute)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.RequestsPerMinute,
+	)
+	return i, err
+}
+
+const updatePassword = `-- name: UpdatePassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdatePasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword sql.NullString
+}
+
+func (q *Queries) UpdatePassword(ctx context.Context, arg UpdatePasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updatePassword, arg.ID, arg.HashedPassword)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
-SET email = $2 , hashed_password = $3 , is_admin = $4, updated_at = NOW()
+SET email = $2 , hashed_password = $3, updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, is_admin, hashed_password
+RETURNING id, created_at, updated_at, email, hashed_password, requests_per_minute
 `
 
 type UpdateUserParams struct {
 	ID             uuid.UUID
 	Email          string
 	HashedPassword sql.NullString
-	IsAdmin        bool
 }
 
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUser,
-		arg.ID,
-		arg.Email,
-		arg.HashedPassword,
-		arg.IsAdmin,
-	)
+	row := q.db.QueryRowContext(ctx, updateUser, arg.ID, arg.Email, arg.HashedPassword)
 	var i User
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Email,
-		&i.IsAdmin,
 		&i.HashedPassword,
+		&i.RequestsPerMinute,
 	)
 	return i, err
 }
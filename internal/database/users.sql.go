@@ -11,34 +11,32 @@ import (
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email,hashed_password,is_admin)
+INSERT INTO users (id, created_at, updated_at, email,hashed_password)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2,
-    $3
+    $2
 )
-RETURNING id, created_at, updated_at, email, is_admin, hashed_password
+RETURNING id, created_at, updated_at, email, hashed_password, requests_per_minute
 `
 
 type CreateUserParams struct {
 	Email          string
 	HashedPassword sql.NullString
-	IsAdmin        bool
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword, arg.IsAdmin)
+	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
 	var i User
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Email,
-		&i.IsAdmin,
 		&i.HashedPassword,
+		&i.RequestsPerMinute,
 	)
 	return i, err
 }
@@ -0,0 +1,156 @@
+package formats
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/o0n1x/mass-translate-package/provider"
+)
+
+// the registry of file types the server accepts. a type is known by its extensions and
+// checked against the content of the upload, so a renamed binary is not sent to a provider
+// as a PDF. providers declare which types they translate with Support
+
+// how much of a file is looked at to tell text from binary and to find markers such as <xliff
+const sniffSize = 4096
+
+type Format struct {
+	Name string
+	// the first one is the canonical extension
+	Extensions []string
+	MIMEType   string
+	// checks the content looks like this type, nil accepts anything
+	Sniff func(r io.ReaderAt, size int64) bool
+	// translated on the server value by value, so every provider that translates text
+	// supports it. see the l10n package
+	Server bool
+}
+
+var registry = []Format{
+	{Name: "Plain text", Extensions: []string{".txt"}, MIMEType: "text/plain; charset=utf-8", Sniff: isText},
+	{Name: "SubRip subtitles", Extensions: []string{".srt"}, MIMEType: "application/x-subrip", Sniff: isTextWith("-->"), Server: true},
+	{Name: "WebVTT subtitles", Extensions: []string{".vtt"}, MIMEType: "text/vtt; charset=utf-8", Sniff: isTextWith("WEBVTT"), Server: true},
+	{Name: "Word", Extensions: []string{".docx"}, MIMEType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", Sniff: isOfficeDocument("word/")},
+	{Name: "PowerPoint", Extensions: []string{".pptx"}, MIMEType: "application/vnd.openxmlformats-officedocument.presentationml.presentation", Sniff: isOfficeDocument("ppt/")},
+	{Name: "Excel", Extensions: []string{".xlsx"}, MIMEType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Sniff: isOfficeDocument("xl/")},
+	{Name: "PDF", Extensions: []string{".pdf"}, MIMEType: "application/pdf", Sniff: hasPrefix("%PDF-")},
+	{Name: "HTML", Extensions: []string{".html", ".htm"}, MIMEType: "text/html; charset=utf-8", Sniff: isTextWith("<")},
+	{Name: "XLIFF", Extensions: []string{".xlf", ".xliff"}, MIMEType: "application/xliff+xml", Sniff: isTextWith("<xliff")},
+	{Name: "gettext PO", Extensions: []string{".po", ".pot"}, MIMEType: "text/x-gettext-translation", Sniff: isTextWith("msgid"), Server: true},
+	{Name: "JSON", Extensions: []string{".json"}, MIMEType: "application/json", Sniff: isText, Server: true},
+	{Name: "Android strings", Extensions: []string{".xml"}, MIMEType: "application/xml", Sniff: isTextWith("<resources"), Server: true},
+	{Name: "Apple strings", Extensions: []string{".strings"}, MIMEType: "text/plain; charset=utf-8", Sniff: isText, Server: true},
+	{Name: "YAML", Extensions: []string{".yaml", ".yml"}, MIMEType: "application/yaml", Sniff: isText, Server: true},
+	{Name: "Java properties", Extensions: []string{".properties"}, MIMEType: "text/x-java-properties", Sniff: isText, Server: true},
+}
+
+// extensions each provider translates
+var support = map[provider.Provider][]string{
+	provider.DeepL: {".txt", ".docx", ".pptx", ".xlsx", ".pdf", ".html", ".htm", ".xlf", ".xliff"},
+}
+
+// adds a file type, extensions already registered are taken over by it
+func Register(f Format) {
+	for _, ext := range f.Extensions {
+		registry = slices.DeleteFunc(registry, func(known Format) bool {
+			return slices.Contains(known.Extensions, ext)
+		})
+	}
+	registry = append(registry, f)
+}
+
+// declares the extensions a provider translates, replacing what it declared before
+func Support(p provider.Provider, extensions ...string) {
+	support[p] = extensions
+}
+
+// every registered type, in the order they were registered
+func All() []Format {
+	return slices.Clone(registry)
+}
+
+// the type of a file by the extension of its name
+func Lookup(filename string) (Format, bool) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, f := range registry {
+		if slices.Contains(f.Extensions, ext) {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// the extensions a provider declared and those of the types translated on the server
+func Supported(p provider.Provider) []string {
+	extensions := slices.Clone(support[p])
+	for _, f := range registry {
+		if !f.Server {
+			continue
+		}
+		for _, ext := range f.Extensions {
+			if !slices.Contains(extensions, ext) {
+				extensions = append(extensions, ext)
+			}
+		}
+	}
+	return extensions
+}
+
+func (f Format) Matches(r io.ReaderAt, size int64) bool {
+	return f.Sniff == nil || f.Sniff(r, size)
+}
+
+func head(r io.ReaderAt, size int64) []byte {
+	data := make([]byte, min(size, sniffSize))
+	n, _ := r.ReadAt(data, 0)
+	return data[:n]
+}
+
+// UTF-8 or ASCII text without NUL bytes. a multi byte character cut off at the end of the
+// sniffed part still counts as text
+func isText(r io.ReaderAt, size int64) bool {
+	data := head(r, size)
+	if bytes.IndexByte(data, 0) >= 0 {
+		return false
+	}
+	if int64(len(data)) < size {
+		for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+	return utf8.Valid(data) && strings.HasPrefix(http.DetectContentType(data), "text/")
+}
+
+func isTextWith(marker string) func(io.ReaderAt, int64) bool {
+	return func(r io.ReaderAt, size int64) bool {
+		return isText(r, size) && bytes.Contains(bytes.ToLower(head(r, size)), []byte(strings.ToLower(marker)))
+	}
+}
+
+func hasPrefix(prefix string) func(io.ReaderAt, int64) bool {
+	return func(r io.ReaderAt, size int64) bool {
+		return bytes.HasPrefix(head(r, size), []byte(prefix))
+	}
+}
+
+// office files are zip archives with a [Content_Types].xml and a folder per application
+func isOfficeDocument(folder string) func(io.ReaderAt, int64) bool {
+	return func(r io.ReaderAt, size int64) bool {
+		archive, err := zip.NewReader(r, size)
+		if err != nil {
+			return false
+		}
+		contentTypes, inFolder := false, false
+		for _, file := range archive.File {
+			contentTypes = contentTypes || file.Name == "[Content_Types].xml"
+			inFolder = inFolder || strings.HasPrefix(file.Name, folder)
+		}
+		return contentTypes && inFolder
+	}
+}
@@ -0,0 +1,56 @@
+package glossary
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-package/lang"
+	"github.com/o0n1x/mass-translate-package/provider"
+)
+
+// handles glossaries passed to providers. the mass-translate-package Request has no
+// glossary field yet, so providers that support glossaries implement Translator and
+// get the glossary alongside the request
+
+type Entry struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+type Glossary struct {
+	ID         uuid.UUID
+	UpdatedAt  time.Time
+	SourceLang lang.Language
+	TargetLang lang.Language
+	Entries    []Entry
+}
+
+type Translator interface {
+	TranslateWithGlossary(ctx context.Context, req provider.Request, g Glossary) (provider.Response, error)
+}
+
+// implemented by translators that keep a copy of glossaries with the provider, it is
+// deleted along with the glossary
+type Remover interface {
+	DeleteGlossary(ctx context.Context, id uuid.UUID)
+}
+
+// identifies a glossary version, it changes whenever the glossary is edited so
+// anything derived from an older version is not reused
+func (g Glossary) Version() string {
+	return fmt.Sprintf("%s@%d", g.ID, g.UpdatedAt.UnixNano())
+}
+
+// glossaries are defined per base language, so an EN glossary applies to EN-US and EN-GB
+func BaseLanguage(l lang.Language) string {
+	base, _, _ := strings.Cut(l.Upper(), "-")
+	return base
+}
+
+// checks the glossary can be used for a translation between from and to
+func (g Glossary) Matches(from lang.Language, to lang.Language) bool {
+	return BaseLanguage(g.SourceLang) == BaseLanguage(from) && BaseLanguage(g.TargetLang) == BaseLanguage(to)
+}
@@ -0,0 +1,147 @@
+package l10n
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Android strings.xml resources. <string>, the items of <string-array> and of <plurals> are
+// translated unless marked translatable="false". the content is kept as raw XML, markup such
+// as <b> or <xliff:g>, entities and escapes other than \' and \" are placeholders
+
+var androidPlaceholders = regexp.MustCompile(`^(?:<xliff:g[^>]*>.*?</xliff:g>|<[^>]*>|&[#\w]+;|\\u[0-9a-fA-F]{4}|\\[^'"])`)
+
+var entityPattern = regexp.MustCompile(`^&[#\w]+;`)
+
+func parseAndroid(data []byte) (source, error) {
+	s := &spans{data: data, encode: encodeAndroid}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	// whether the items of the current array or plurals are translated
+	items := false
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "string-array", "plurals":
+				items = isTranslatable(t)
+				continue
+			case "string":
+			case "item":
+				if !items {
+					continue
+				}
+			default:
+				continue
+			}
+			start := int(decoder.InputOffset())
+			end, err := elementEnd(decoder)
+			if err != nil {
+				return nil, fmt.Errorf("invalid XML: %w", err)
+			}
+			inner := string(data[start:end])
+			if !isTranslatable(t) || strings.Contains(inner, "<![CDATA[") {
+				continue
+			}
+			// "..." keeps white space as it is, the quotes stay around the translation
+			if len(inner) >= 2 && inner[0] == '"' && inner[len(inner)-1] == '"' && inner[len(inner)-2] != '\\' {
+				start, end, inner = start+1, end-1, inner[1:len(inner)-1]
+			}
+			s.add(start, end, decodeAndroid(inner))
+		case xml.EndElement:
+			if t.Name.Local == "string-array" || t.Name.Local == "plurals" {
+				items = false
+			}
+		}
+	}
+	return s, nil
+}
+
+func isTranslatable(t xml.StartElement) bool {
+	for _, attr := range t.Attr {
+		if attr.Name.Local == "translatable" && attr.Value == "false" {
+			return false
+		}
+	}
+	return true
+}
+
+// reads up to the end of the element whose start was just read and returns where its end tag
+// starts
+func elementEnd(decoder *xml.Decoder) (int, error) {
+	depth := 1
+	for {
+		offset := int(decoder.InputOffset())
+		token, err := decoder.RawToken()
+		if err != nil {
+			return 0, err
+		}
+		switch token.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth == 0 {
+				return offset, nil
+			}
+		}
+	}
+}
+
+func decodeAndroid(value string) string {
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			if value[i+1] != '\'' && value[i+1] != '"' {
+				out.WriteByte(value[i])
+			}
+			i++
+		}
+		out.WriteByte(value[i])
+	}
+	return out.String()
+}
+
+// escapes quotes outside of tags, a leading @ or ? and any & that does not start an entity
+func encodeAndroid(value string) string {
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '<':
+			end := strings.IndexByte(value[i:], '>')
+			if end < 0 {
+				out.WriteString("&lt;")
+				continue
+			}
+			out.WriteString(value[i : i+end+1])
+			i += end
+		case value[i] == '\\' && i+1 < len(value):
+			out.WriteString(value[i : i+2])
+			i++
+		case value[i] == '\'' || value[i] == '"':
+			out.WriteByte('\\')
+			out.WriteByte(value[i])
+		case value[i] == '&' && entityPattern.FindString(value[i:]) == "":
+			out.WriteString("&amp;")
+		case (value[i] == '@' || value[i] == '?') && i == 0:
+			out.WriteByte('\\')
+			out.WriteByte(value[i])
+		default:
+			out.WriteByte(value[i])
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,71 @@
+package l10n
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// JSON bundles as used by i18next, react-intl or ARB files. every string value is translated
+// wherever it is nested, keys are not. values under keys starting with @ are metadata, such as
+// the descriptions of an ARB file, and are left alone
+
+type jsonLevel struct {
+	object bool
+	// whether the next string of an object is a key
+	expectKey bool
+	skip      bool
+	// whether the value being read is under a metadata key
+	skipValue bool
+}
+
+func parseJSON(data []byte) (source, error) {
+	if !json.Valid(data) {
+		return nil, errors.New("invalid JSON")
+	}
+	s := &spans{data: data, encode: encodeJSON}
+	levels := []jsonLevel{{}}
+	for i := 0; i < len(data); i++ {
+		level := &levels[len(levels)-1]
+		switch data[i] {
+		case '{', '[':
+			skip := level.skip || level.skipValue
+			levels = append(levels, jsonLevel{object: data[i] == '{', expectKey: data[i] == '{', skip: skip})
+		case '}', ']':
+			levels = levels[:len(levels)-1]
+		case ',':
+			level.expectKey = level.object
+			level.skipValue = false
+		case '"':
+			end := i + 1
+			for data[end] != '"' {
+				if data[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			raw := data[i : end+1]
+			if level.expectKey {
+				key := ""
+				_ = json.Unmarshal(raw, &key)
+				level.skipValue = strings.HasPrefix(key, "@")
+				level.expectKey = false
+			} else if !level.skip && !level.skipValue {
+				value := ""
+				_ = json.Unmarshal(raw, &value)
+				s.add(i, end+1, value)
+			}
+			i = end
+		}
+	}
+	return s, nil
+}
+
+func encodeJSON(value string) string {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+	return strings.TrimSuffix(out.String(), "\n")
+}
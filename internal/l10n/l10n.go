@@ -0,0 +1,143 @@
+package l10n
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// translates localization files value by value on the server instead of sending them to a
+// provider as documents. a file is parsed into its translatable values, the values go
+// through the text path like any other text and the file is rebuilt around the translations,
+// so keys, comments and placeholders are left as they were
+
+// a parsed file format, values are decoded from the format's escaping
+type source interface {
+	values() []string
+	// the file with the values that changed replaced by the one at the same index,
+	// the others are left exactly as they were
+	build(values []string, changed []bool) ([]byte, error)
+}
+
+type parser struct {
+	parse func(data []byte) (source, error)
+	// placeholders the format has on top of the common ones, nil for none
+	placeholders *regexp.Regexp
+}
+
+var parsers = map[string]parser{
+	".json":       {parse: parseJSON},
+	".xml":        {parse: parseAndroid, placeholders: androidPlaceholders},
+	".strings":    {parse: parseStrings},
+	".yaml":       {parse: parseYAML},
+	".yml":        {parse: parseYAML},
+	".properties": {parse: parseProperties},
+	".po":         {parse: parsePO},
+	".pot":        {parse: parsePO},
+}
+
+type File struct {
+	source source
+	values []protected
+	// index of each value in Texts, -1 for values that are not translated
+	index []int
+	texts []string
+	// values left in the source language since the provider lost one of their placeholders
+	Lost int
+}
+
+// whether files with this name are translated on the server
+func Supports(filename string) bool {
+	_, ok := parsers[strings.ToLower(filepath.Ext(filename))]
+	return ok
+}
+
+func Parse(filename string, data []byte) (*File, error) {
+	p, ok := parsers[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a localization file", filename)
+	}
+	src, err := p.parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{source: src, texts: []string{}}
+	for _, value := range src.values() {
+		v := protect(value, p.placeholders)
+		f.values = append(f.values, v)
+		if !v.translatable() {
+			f.index = append(f.index, -1)
+			continue
+		}
+		f.index = append(f.index, len(f.texts))
+		f.texts = append(f.texts, v.text)
+	}
+	return f, nil
+}
+
+// the texts to translate, placeholders are replaced by markers such as ⟦0⟧
+func (f *File) Texts() []string {
+	return f.texts
+}
+
+// the file with the translations of Texts in place of the original values
+func (f *File) Build(translations []string) ([]byte, error) {
+	if len(translations) != len(f.texts) {
+		return nil, fmt.Errorf("got %d translations for %d texts", len(translations), len(f.texts))
+	}
+	values := make([]string, len(f.values))
+	changed := make([]bool, len(f.values))
+	f.Lost = 0
+	for i, v := range f.values {
+		// a value that comes back unchanged keeps the escaping it had in the file
+		if f.index[i] < 0 || translations[f.index[i]] == f.texts[f.index[i]] {
+			continue
+		}
+		text, ok := v.restore(translations[f.index[i]])
+		if !ok {
+			f.Lost++
+			continue
+		}
+		values[i], changed[i] = text, true
+	}
+	return f.source.build(values, changed)
+}
+
+// a file kept as the raw bytes around its values, so nothing but the values changes when it
+// is rebuilt. encode turns a value back into the format's escaping
+type spans struct {
+	data    []byte
+	starts  []int
+	ends    []int
+	decoded []string
+	encode  func(string) string
+}
+
+// records the value at data[start:end]
+func (s *spans) add(start int, end int, decoded string) {
+	s.starts = append(s.starts, start)
+	s.ends = append(s.ends, end)
+	s.decoded = append(s.decoded, decoded)
+}
+
+func (s *spans) values() []string {
+	return s.decoded
+}
+
+func (s *spans) build(values []string, changed []bool) ([]byte, error) {
+	var out bytes.Buffer
+	last := 0
+	for i := range s.decoded {
+		if !changed[i] {
+			continue
+		}
+		out.Write(s.data[last:s.starts[i]])
+		out.WriteString(s.encode(values[i]))
+		last = s.ends[i]
+	}
+	out.Write(s.data[last:])
+	return out.Bytes(), nil
+}
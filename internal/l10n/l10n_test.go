@@ -0,0 +1,296 @@
+package l10n
+
+import (
+	"strings"
+	"testing"
+)
+
+var files = []struct {
+	filename string
+	data     string
+}{
+	{"en.json", `{
+  "title": "Hello {name}",
+  "count": "{count, plural, =0 {No items} one {# item} other {# items}}",
+  "escaped": "Caf\u00e9 \"quoted\" \\ back\/slash",
+  "nested": {"list": ["First", "Second", 3, true, null]},
+  "@title": {"description": "shown on the home page"}
+}
+`},
+	{"strings.xml", `<?xml version="1.0" encoding="utf-8"?>
+<resources>
+    <!-- a comment -->
+    <string name="app_name" translatable="false">Mass Translate</string>
+    <string name="greeting">Hello %1$s, you\'re <b>welcome</b> &amp; more</string>
+    <string name="spaced">"  keeps   spaces  "</string>
+    <string name="escape">Line one\nLine two \@ \u00e9</string>
+    <string name="cdata"><![CDATA[<i>left alone</i>]]></string>
+    <string-array name="planets">
+        <item>Mercury</item>
+        <item>Venus</item>
+    </string-array>
+    <plurals name="songs">
+        <item quantity="one">%d song</item>
+        <item quantity="other">%d songs</item>
+    </plurals>
+</resources>
+`},
+	{"Localizable.strings", `/* the greeting */
+"greeting" = "Hello %@, \"friend\"\n";
+// a line comment
+"caf\u00e9" = "Caf\U00e9";
+"empty" = "";
+`},
+	{"en.yml", `# a comment
+en:
+  greeting: "Hello %{name}"
+  plain: Good morning
+  list:
+    - One
+    - Two
+  number: 3
+`},
+	{"messages.properties", `# a comment
+! another
+greeting = Hello {0}, welcome
+colon:value with \
+    a continued line
+escaped=Caf\u00e9 \t tab
+  spaced\ key value
+empty=
+`},
+	{"messages.po", `# a comment
+msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=UTF-8\n"
+
+#: src/main.c:10
+msgid "Hello %s"
+msgstr ""
+
+msgctxt "menu"
+msgid ""
+"Open "
+"file"
+msgstr ""
+"Datei "
+"öffnen"
+
+msgid "One file"
+msgid_plural "%d files"
+msgstr[0] ""
+msgstr[1] ""
+
+#~ msgid "Obsolete"
+#~ msgstr ""
+`},
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, tt := range files {
+		for _, newline := range []string{"\n", "\r\n"} {
+			data := strings.ReplaceAll(tt.data, "\n", newline)
+			f, err := Parse(tt.filename, []byte(data))
+			if err != nil {
+				t.Fatalf("%s: Parse: %v", tt.filename, err)
+			}
+			if len(f.Texts()) == 0 {
+				t.Errorf("%s: nothing to translate", tt.filename)
+			}
+			out, err := f.Build(f.Texts())
+			if err != nil {
+				t.Fatalf("%s: Build: %v", tt.filename, err)
+			}
+			if string(out) != data {
+				t.Errorf("%s: Build with the original texts changed the file\ngot:\n%q\nwant:\n%q", tt.filename, out, data)
+			}
+		}
+	}
+}
+
+// every value is translated and the file still parses to the translations. PO files are
+// left out, their translations go into msgstr while the values read back are the msgids
+func TestTranslate(t *testing.T) {
+	for _, tt := range files {
+		if strings.HasSuffix(tt.filename, ".po") {
+			continue
+		}
+		f, err := Parse(tt.filename, []byte(tt.data))
+		if err != nil {
+			t.Fatalf("%s: Parse: %v", tt.filename, err)
+		}
+		translations := []string{}
+		for _, text := range f.Texts() {
+			translations = append(translations, strings.ToUpper(text))
+		}
+		out, err := f.Build(translations)
+		if err != nil {
+			t.Fatalf("%s: Build: %v", tt.filename, err)
+		}
+		if f.Lost != 0 {
+			t.Errorf("%s: %d values lost", tt.filename, f.Lost)
+		}
+
+		translated, err := Parse(tt.filename, out)
+		if err != nil {
+			t.Fatalf("%s: Parse of the translation: %v\n%s", tt.filename, err, out)
+		}
+		want := f.values
+		got := translated.values
+		if len(got) != len(want) {
+			t.Fatalf("%s: got %d values, want %d\n%s", tt.filename, len(got), len(want), out)
+		}
+		for i := range want {
+			if f.index[i] < 0 {
+				continue
+			}
+			restored, _ := want[i].restore(strings.ToUpper(want[i].text))
+			value, _ := got[i].restore(got[i].text)
+			if value != restored {
+				t.Errorf("%s: value %d is %q, want %q", tt.filename, i, value, restored)
+			}
+		}
+	}
+}
+
+func TestTranslatedFiles(t *testing.T) {
+	tests := []struct {
+		filename string
+		data     string
+		want     string
+	}{
+		{
+			"en.json",
+			`{"a": "Hello {name}", "@a": {"description": "Hello"}}`,
+			`{"a": "HELLO {name}", "@a": {"description": "Hello"}}`,
+		},
+		{
+			"strings.xml",
+			`<resources><string name="a">It\'s %1$s &amp; <b>bold</b></string></resources>`,
+			`<resources><string name="a">IT\'S %1$s &amp; <b>BOLD</b></string></resources>`,
+		},
+		{
+			"Localizable.strings",
+			`"a" = "Say \"hi\" to %@";`,
+			`"a" = "SAY \"HI\" TO %@";`,
+		},
+		{
+			"a.properties",
+			"a = Hello {0}\n",
+			"a = HELLO {0}\n",
+		},
+		{
+			"a.po",
+			"msgid \"%d file\"\nmsgid_plural \"%d files\"\nmsgstr[0] \"\"\nmsgstr[1] \"\"\n",
+			"msgid \"%d file\"\nmsgid_plural \"%d files\"\nmsgstr[0] \"%d FILE\"\nmsgstr[1] \"%d FILES\"\n",
+		},
+	}
+	for _, tt := range tests {
+		f, err := Parse(tt.filename, []byte(tt.data))
+		if err != nil {
+			t.Fatalf("%s: Parse: %v", tt.filename, err)
+		}
+		translations := []string{}
+		for _, text := range f.Texts() {
+			translations = append(translations, strings.ToUpper(text))
+		}
+		out, err := f.Build(translations)
+		if err != nil {
+			t.Fatalf("%s: Build: %v", tt.filename, err)
+		}
+		if string(out) != tt.want {
+			t.Errorf("%s:\ngot:  %q\nwant: %q", tt.filename, out, tt.want)
+		}
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		// the text sent to the provider
+		text string
+	}{
+		{"brace", "Hello {name}, you have {0} messages", "Hello ⟦0⟧, you have ⟦1⟧ messages"},
+		{"double brace", "Hi {{user}}", "Hi ⟦0⟧"},
+		{"printf", "%s has %1$d items, %.2f%% done", "⟦0⟧ has ⟦1⟧ items, ⟦2⟧⟦3⟧ done"},
+		{"objective-c", "Hello %@", "Hello ⟦0⟧"},
+		{"python and ruby", "%(name)s and %{count}", "⟦0⟧ and ⟦1⟧"},
+		{"percent sign", "100% sure", "100% sure"},
+		{
+			"icu plural",
+			"{count, plural, =0 {No items} one {# item} other {# items for {name}}}",
+			"⟦0⟧No items⟦1⟧⟦2⟧ item⟦3⟧⟦4⟧ items for ⟦5⟧⟦6⟧",
+		},
+		{"icu select", "{gender, select, male {He} other {They}} left", "⟦0⟧He⟦1⟧They⟦2⟧ left"},
+	}
+	for _, tt := range tests {
+		p := protect(tt.value, nil)
+		if p.text != tt.text {
+			t.Errorf("%s: protect(%q) = %q, want %q", tt.name, tt.value, p.text, tt.text)
+			continue
+		}
+		restored, ok := p.restore(p.text)
+		if !ok || restored != tt.value {
+			t.Errorf("%s: restore gave %q, %v, want %q", tt.name, restored, ok, tt.value)
+		}
+	}
+}
+
+func TestAndroidPlaceholders(t *testing.T) {
+	tests := []struct {
+		value string
+		text  string
+	}{
+		{`Tap <b>here</b> for %1$s`, "Tap ⟦0⟧here⟦1⟧ for ⟦2⟧"},
+		{`Line\nbreak and \u00e9`, "Line⟦0⟧break and ⟦1⟧"},
+		{`Tom &amp; Jerry`, "Tom ⟦0⟧ Jerry"},
+		{`Hi <xliff:g id="name">%s</xliff:g>!`, "Hi ⟦0⟧!"},
+	}
+	for _, tt := range tests {
+		p := protect(tt.value, androidPlaceholders)
+		if p.text != tt.text {
+			t.Errorf("protect(%q) = %q, want %q", tt.value, p.text, tt.text)
+		}
+	}
+
+	// \' and \" are decoded and escaped again, other escapes stay as they are
+	if got := decodeAndroid(`It\'s \"x\" \n`); got != `It's "x" \n` {
+		t.Errorf("decodeAndroid = %q", got)
+	}
+	if got := encodeAndroid(`It's "x" \n @ & &amp;`); got != `It\'s \"x\" \n @ &amp; &amp;` {
+		t.Errorf("encodeAndroid = %q", got)
+	}
+	if got := encodeAndroid(`@string`); got != `\@string` {
+		t.Errorf("encodeAndroid = %q", got)
+	}
+}
+
+// a translation that lost or repeated a placeholder leaves the value untranslated
+func TestLostPlaceholder(t *testing.T) {
+	f, err := Parse("en.json", []byte(`{"a": "Hello {name}", "b": "Bye {name}", "c": "Plain"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.Build([]string{"Bonjour", "Au revoir ⟦0⟧ ⟦0⟧", "Simple"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Lost != 2 {
+		t.Errorf("Lost = %d, want 2", f.Lost)
+	}
+	want := `{"a": "Hello {name}", "b": "Bye {name}", "c": "Simple"}`
+	if string(out) != want {
+		t.Errorf("got %s, want %s", out, want)
+	}
+}
+
+func TestNotTranslated(t *testing.T) {
+	f, err := Parse("en.json", []byte(`{"a": "{name}", "b": "%d", "c": "...", "d": ""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Texts()) != 0 {
+		t.Errorf("Texts() = %q, want none", f.Texts())
+	}
+}
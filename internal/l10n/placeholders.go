@@ -0,0 +1,146 @@
+package l10n
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// placeholders are swapped for numbered markers before a value goes to the provider and put
+// back afterwards, so {name}, %1$s or the structure of an ICU plural come back untouched.
+// the text inside ICU plural and select branches is still translated
+
+// printf style (%s, %1$d, %@, %.2f), python (%(name)s) and ruby (%{name}) placeholders.
+// there is no space flag so "100% sure" is left alone
+var printfPattern = regexp.MustCompile(`^(?:%(?:\d+\$)?[-+#0]*(?:\d+|\*)?(?:\.(?:\d+|\*))?(?:hh|h|ll|l|L|q|j|z|t)?[diouxXeEfFgGaAcCsSp@%]|%\([A-Za-z_]\w*\)[sdif]|%\{[A-Za-z_]\w*\})`)
+
+var icuPattern = regexp.MustCompile(`^\{\s*[\w.]+\s*,\s*(plural|select|selectordinal)\s*,`)
+
+type protected struct {
+	text         string
+	placeholders []string
+}
+
+// extra matches format specific placeholders at the start of the text it is given, nil for none
+func protect(s string, extra *regexp.Regexp) protected {
+	p := protected{}
+	p.text = p.scan(s, extra, false)
+	return p
+}
+
+func marker(i int) string {
+	return fmt.Sprintf("⟦%d⟧", i)
+}
+
+func (p *protected) hide(placeholder string) string {
+	p.placeholders = append(p.placeholders, placeholder)
+	return marker(len(p.placeholders) - 1)
+}
+
+// inPlural makes # a placeholder, it stands for the number in plural branches
+func (p *protected) scan(s string, extra *regexp.Regexp, inPlural bool) string {
+	var out strings.Builder
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		if extra != nil {
+			if match := extra.FindString(rest); match != "" {
+				out.WriteString(p.hide(match))
+				i += len(match)
+				continue
+			}
+		}
+		if match := printfPattern.FindString(rest); match != "" {
+			out.WriteString(p.hide(match))
+			i += len(match)
+			continue
+		}
+		if rest[0] == '#' && inPlural {
+			out.WriteString(p.hide("#"))
+			i++
+			continue
+		}
+		if rest[0] == '{' {
+			end := closingBrace(rest)
+			if end > 0 {
+				out.WriteString(p.braces(rest[:end+1], extra))
+				i += end + 1
+				continue
+			}
+		}
+		out.WriteByte(rest[0])
+		i++
+	}
+	return out.String()
+}
+
+// a {...} block. ICU plurals and selects keep their branch texts translatable, anything
+// else such as {name}, {0} or {{name}} is one placeholder
+func (p *protected) braces(block string, extra *regexp.Regexp) string {
+	header := icuPattern.FindStringSubmatch(block)
+	if header == nil {
+		return p.hide(block)
+	}
+	plural := header[1] != "select"
+
+	// {count, plural, =0 {none} one {# item} other {# items}}
+	var out strings.Builder
+	skeleton := header[0]
+	body := block[len(header[0]) : len(block)-1]
+	for i := 0; i < len(body); {
+		if body[i] != '{' {
+			skeleton += string(body[i])
+			i++
+			continue
+		}
+		end := closingBrace(body[i:])
+		if end < 0 {
+			return p.hide(block)
+		}
+		out.WriteString(p.hide(skeleton + "{"))
+		out.WriteString(p.scan(body[i+1:i+end], extra, plural))
+		skeleton = "}"
+		i += end + 1
+	}
+	out.WriteString(p.hide(skeleton + "}"))
+	return out.String()
+}
+
+// the index of the } closing the { at the start of s, -1 if it is never closed
+func closingBrace(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// whether anything but placeholders and punctuation is left to translate
+func (p protected) translatable() bool {
+	text := p.text
+	for i := range p.placeholders {
+		text = strings.Replace(text, marker(i), "", 1)
+	}
+	return strings.ContainsFunc(text, unicode.IsLetter)
+}
+
+// puts the placeholders back into a translation, false if the provider lost or repeated one
+func (p protected) restore(translated string) (string, bool) {
+	for i := range p.placeholders {
+		if strings.Count(translated, marker(i)) != 1 {
+			return "", false
+		}
+	}
+	for i, placeholder := range p.placeholders {
+		translated = strings.Replace(translated, marker(i), placeholder, 1)
+	}
+	return translated, true
+}
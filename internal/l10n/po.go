@@ -0,0 +1,174 @@
+package l10n
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// gettext .po and .pot files. msgid is translated into msgstr and msgid_plural into the
+// plural forms msgstr[1] and up, the header entry and obsolete #~ entries are left alone.
+// a msgstr written back is a single line, whatever number of lines it had before
+
+type poEntry struct {
+	msgid     string
+	plural    string
+	hasPlural bool
+	msgstrs   []poMsgstr
+}
+
+// a msgstr or msgstr[n] line with its continuation lines
+type poMsgstr struct {
+	index int
+	start int
+	end   int
+}
+
+type poSource struct {
+	data    []byte
+	entries []poEntry
+}
+
+func parsePO(data []byte) (source, error) {
+	s := &poSource{data: data}
+	entry := poEntry{}
+	// the string continuation lines are appended to
+	var current *string
+	msgstr := -1
+	flush := func() {
+		if len(entry.msgstrs) > 0 && entry.msgid != "" {
+			s.entries = append(s.entries, entry)
+		}
+		entry, current, msgstr = poEntry{}, nil, -1
+	}
+
+	for i, n := 0, 1; i < len(data); n++ {
+		end := bytes.IndexByte(data[i:], '\n') + i + 1
+		if end == i {
+			end = len(data)
+		}
+		line := strings.TrimSpace(string(data[i:end]))
+		keyword, rest, _ := strings.Cut(line, " ")
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			if msgstr >= 0 {
+				flush()
+			}
+			current = nil
+		case strings.HasPrefix(line, `"`):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: string outside of an entry", n)
+			}
+			value, err := unquotePO(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			*current += value
+			if msgstr >= 0 {
+				entry.msgstrs[msgstr].end = end
+			}
+		case keyword == "msgctxt" || keyword == "msgid" || keyword == "msgid_plural":
+			if msgstr >= 0 {
+				flush()
+			}
+			value, err := unquotePO(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			// msgctxt is not translated, it is read so its continuation lines have somewhere to go
+			var ctxt string
+			current = &ctxt
+			switch keyword {
+			case "msgid":
+				current = &entry.msgid
+			case "msgid_plural":
+				current, entry.hasPlural = &entry.plural, true
+			}
+			*current = value
+		case keyword == "msgstr" || strings.HasPrefix(keyword, "msgstr["):
+			index := 0
+			if keyword != "msgstr" {
+				var err error
+				index, err = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(keyword, "msgstr["), "]"))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid %s", n, keyword)
+				}
+			}
+			_, err := unquotePO(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			entry.msgstrs = append(entry.msgstrs, poMsgstr{index: index, start: i, end: end})
+			msgstr = len(entry.msgstrs) - 1
+			var value string
+			current = &value
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q", n, keyword)
+		}
+		i = end
+	}
+	flush()
+	return s, nil
+}
+
+func unquotePO(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("expected a quoted string, got %s", s)
+	}
+	value, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid string %s", s)
+	}
+	return value, nil
+}
+
+func (s *poSource) values() []string {
+	values := []string{}
+	for _, e := range s.entries {
+		values = append(values, e.msgid)
+		if e.hasPlural {
+			values = append(values, e.plural)
+		}
+	}
+	return values
+}
+
+func (s *poSource) build(values []string, changed []bool) ([]byte, error) {
+	var out bytes.Buffer
+	last, v := 0, 0
+	for _, e := range s.entries {
+		singular, plural := v, v
+		if e.hasPlural {
+			plural++
+		}
+		v = plural + 1
+
+		for _, m := range e.msgstrs {
+			value := singular
+			if m.index > 0 {
+				value = plural
+			}
+			if !changed[value] {
+				continue
+			}
+			out.Write(s.data[last:m.start])
+			if e.hasPlural {
+				fmt.Fprintf(&out, "msgstr[%d] ", m.index)
+			} else {
+				out.WriteString("msgstr ")
+			}
+			out.WriteString(quotePO(values[value]))
+			out.WriteByte('\n')
+			last = m.end
+		}
+	}
+	out.Write(s.data[last:])
+	return out.Bytes(), nil
+}
+
+func quotePO(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	return `"` + replacer.Replace(value) + `"`
+}
@@ -0,0 +1,143 @@
+package l10n
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Java .properties files, key=value, key: value or key value lines with # and ! comments
+// and values continued on the next line by a trailing backslash
+
+func parseProperties(data []byte) (source, error) {
+	s := &spans{data: data, encode: encodeProperties}
+	for i := 0; i < len(data); {
+		i = skipBlanks(data, i)
+		if i >= len(data) {
+			break
+		}
+		if data[i] == '\n' || data[i] == '\r' {
+			i++
+			continue
+		}
+		if data[i] == '#' || data[i] == '!' {
+			i = lineEnd(data, i)
+			continue
+		}
+
+		// the key ends at the first unescaped separator or blank
+		for i < len(data) && !isLineBreak(data[i]) && !isSeparator(data[i]) {
+			if data[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		i = skipBlanks(data, i)
+		if i < len(data) && (data[i] == '=' || data[i] == ':') {
+			i = skipBlanks(data, i+1)
+		}
+
+		start := i
+		for i < len(data) && !isLineBreak(data[i]) {
+			if data[i] == '\\' && i+1 < len(data) {
+				i++
+				if data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+			}
+			i++
+		}
+		if i > start {
+			s.add(start, i, decodeProperties(string(data[start:i])))
+		}
+	}
+	return s, nil
+}
+
+func isLineBreak(b byte) bool {
+	return b == '\n' || b == '\r'
+}
+
+func isSeparator(b byte) bool {
+	return b == '=' || b == ':' || b == ' ' || b == '\t' || b == '\f'
+}
+
+func skipBlanks(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\f') {
+		i++
+	}
+	return i
+}
+
+func lineEnd(data []byte, i int) int {
+	for i < len(data) && !isLineBreak(data[i]) {
+		i++
+	}
+	return i
+}
+
+func decodeProperties(value string) string {
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			out.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case '\r', '\n':
+			// a continued line, the blanks starting the next line are not part of the value
+			if value[i] == '\r' && i+1 < len(value) && value[i+1] == '\n' {
+				i++
+			}
+			for i+1 < len(value) && (value[i+1] == ' ' || value[i+1] == '\t' || value[i+1] == '\f') {
+				i++
+			}
+		case 't':
+			out.WriteByte('\t')
+		case 'n':
+			out.WriteByte('\n')
+		case 'r':
+			out.WriteByte('\r')
+		case 'f':
+			out.WriteByte('\f')
+		case 'u':
+			code, err := strconv.ParseUint(value[i+1:min(i+5, len(value))], 16, 16)
+			if err != nil || i+5 > len(value) {
+				out.WriteByte('u')
+				continue
+			}
+			out.WriteRune(rune(code))
+			i += 4
+		default:
+			out.WriteByte(value[i])
+		}
+	}
+	return out.String()
+}
+
+// non ASCII characters are written as they are, the file is expected to be UTF-8
+func encodeProperties(value string) string {
+	var out strings.Builder
+	for i, r := range value {
+		switch r {
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\f':
+			out.WriteString(`\f`)
+		case ' ':
+			if i == 0 {
+				out.WriteByte('\\')
+			}
+			out.WriteRune(r)
+		default:
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,86 @@
+package l10n
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// iOS and macOS .strings files, "key" = "value"; pairs with C style comments
+
+func parseStrings(data []byte) (source, error) {
+	s := &spans{data: data, encode: encodeStrings}
+	afterEquals := false
+	for i := 0; i < len(data); {
+		switch {
+		case data[i] == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := strings.Index(string(data[i+2:]), "*/")
+			if end < 0 {
+				return nil, errors.New("unterminated comment")
+			}
+			i += end + 4
+		case data[i] == '/' && i+1 < len(data) && data[i+1] == '/':
+			i = lineEnd(data, i)
+		case data[i] == '"':
+			end := i + 1
+			for end < len(data) && data[end] != '"' {
+				if data[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(data) {
+				return nil, errors.New("unterminated string")
+			}
+			if afterEquals {
+				s.add(i+1, end, decodeStrings(string(data[i+1:end])))
+				afterEquals = false
+			}
+			i = end + 1
+		case data[i] == '=':
+			afterEquals = true
+			i++
+		case data[i] == ';':
+			afterEquals = false
+			i++
+		default:
+			i++
+		}
+	}
+	return s, nil
+}
+
+func decodeStrings(value string) string {
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			out.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case 'U', 'u':
+			code, err := strconv.ParseUint(value[i+1:min(i+5, len(value))], 16, 16)
+			if err != nil || i+5 > len(value) {
+				out.WriteByte(value[i])
+				continue
+			}
+			out.WriteRune(rune(code))
+			i += 4
+		default:
+			out.WriteByte(value[i])
+		}
+	}
+	return out.String()
+}
+
+func encodeStrings(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	return replacer.Replace(value)
+}
@@ -0,0 +1,89 @@
+package l10n
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// YAML bundles such as Rails locale files. every string scalar that is not a key is translated,
+// comments are kept but a translated file is written back with the library's formatting
+
+type yamlSource struct {
+	data      []byte
+	documents []*yaml.Node
+	nodes     []*yaml.Node
+}
+
+func parseYAML(data []byte) (source, error) {
+	s := &yamlSource{data: data}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		document := &yaml.Node{}
+		err := decoder.Decode(document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		s.documents = append(s.documents, document)
+		s.collect(document)
+	}
+	return s, nil
+}
+
+func (s *yamlSource) collect(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			s.collect(child)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			s.collect(node.Content[i])
+		}
+	case yaml.ScalarNode:
+		if node.ShortTag() == "!!str" {
+			s.nodes = append(s.nodes, node)
+		}
+	}
+}
+
+func (s *yamlSource) values() []string {
+	values := make([]string, len(s.nodes))
+	for i, node := range s.nodes {
+		values[i] = node.Value
+	}
+	return values
+}
+
+// a file without changes is returned as it was rather than in the library's formatting
+func (s *yamlSource) build(values []string, changed []bool) ([]byte, error) {
+	if !slices.Contains(changed, true) {
+		return s.data, nil
+	}
+	for i, node := range s.nodes {
+		if changed[i] {
+			node.Value = values[i]
+		}
+	}
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	for _, document := range s.documents {
+		err := encoder.Encode(document)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := encoder.Close()
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,137 @@
+package lockout
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// counts failed logins per email and per client IP in redis. every failure past a few
+// free ones makes the next attempt wait twice as long, and an email is locked for a
+// while after too many failures. emails are tracked whether or not an account exists,
+// so the answers never tell which emails are registered
+
+// failures allowed before attempts are slowed down. IPs get more since they can be shared
+const freeEmailFailures = 2
+const freeIPFailures = 10
+
+const baseDelay = time.Second
+const maxDelay = time.Minute
+
+type Lockout struct {
+	redis       *redis.Client
+	maxFailures int64
+	duration    time.Duration
+}
+
+// maxFailures failed logins within duration of each other lock the email for duration
+func New(client *redis.Client, maxFailures int64, duration time.Duration) *Lockout {
+	return &Lockout{redis: client, maxFailures: maxFailures, duration: duration}
+}
+
+// how long an email stays locked
+func (l *Lockout) Duration() time.Duration {
+	return l.duration
+}
+
+// KEYS are the lock of the email, the failures of the email and the IP and their backoffs.
+// ARGV is the lock duration and the base and max delay in milliseconds, maxFailures and the
+// free failures of the email and the IP. returns the wait, or 0 and 1 if the attempt locked
+// the email
+var attempt = redis.NewScript(`
+local duration = tonumber(ARGV[1])
+local wait = 0
+for _, key in ipairs({KEYS[1], KEYS[4], KEYS[5]}) do
+	wait = math.max(wait, redis.call('PTTL', key))
+end
+if wait > 0 then
+	return {wait, 0}
+end
+
+local function backoff(failures, free)
+	if failures <= free then
+		return 0
+	end
+	return math.min(tonumber(ARGV[2]) * 2 ^ (failures - free - 1), tonumber(ARGV[3]))
+end
+local failures = {}
+for i, key in ipairs({KEYS[2], KEYS[3]}) do
+	failures[i] = redis.call('INCR', key)
+	redis.call('PEXPIRE', key, duration)
+	local delay = backoff(failures[i], tonumber(ARGV[4 + i]))
+	if delay > 0 then
+		redis.call('SET', KEYS[3 + i], 1, 'PX', math.floor(delay))
+	end
+end
+
+if failures[1] >= tonumber(ARGV[4]) then
+	-- counting starts over once the lock runs out
+	redis.call('SET', KEYS[1], 1, 'PX', duration)
+	redis.call('DEL', KEYS[2], KEYS[4])
+	return {0, 1}
+end
+return {0, 0}
+`)
+
+// KEYS are the lock and failures of the email, the backoff of the email and the failures of
+// the IP
+var forget = redis.NewScript(`
+redis.call('DEL', KEYS[1], KEYS[2], KEYS[3])
+if tonumber(redis.call('GET', KEYS[4]) or 0) > 0 then
+	redis.call('DECR', KEYS[4])
+end
+return 1
+`)
+
+// counts a login attempt as a failure before the password is checked, so concurrent guesses
+// can not all get in before the first one is counted. returns how long the caller has to
+// wait before trying again, zero if they may try now, and whether this attempt locked the
+// email. an attempt that has to wait is not counted
+func (l *Lockout) Attempt(ctx context.Context, email string, ip string) (time.Duration, bool, error) {
+	email = normalize(email)
+	keys := []string{
+		lockedKey(email),
+		failuresKey("email", email), failuresKey("ip", ip),
+		backoffKey("email", email), backoffKey("ip", ip),
+	}
+	result, err := attempt.Run(ctx, l.redis, keys,
+		l.duration.Milliseconds(), baseDelay.Milliseconds(), maxDelay.Milliseconds(),
+		l.maxFailures, freeEmailFailures, freeIPFailures).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	return time.Duration(result[0]) * time.Millisecond, result[1] == 1, nil
+}
+
+// forgets the failures of the email after a good password, lifting the lock if the attempt
+// set it. the IP only gets back the attempt that succeeded so one good account can not be
+// used to reset the limit for guessing others
+func (l *Lockout) Success(ctx context.Context, email string, ip string) error {
+	email = normalize(email)
+	keys := []string{lockedKey(email), failuresKey("email", email), backoffKey("email", email), failuresKey("ip", ip)}
+	return forget.Run(ctx, l.redis, keys).Err()
+}
+
+// lifts the lock of the email and forgets its failures
+func (l *Lockout) Unlock(ctx context.Context, email string) error {
+	email = normalize(email)
+	return l.redis.Del(ctx, lockedKey(email), failuresKey("email", email), backoffKey("email", email)).Err()
+}
+
+func normalize(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func failuresKey(kind string, id string) string {
+	return "login:failures:" + kind + ":" + id
+}
+
+func backoffKey(kind string, id string) string {
+	return "login:backoff:" + kind + ":" + id
+}
+
+func lockedKey(email string) string {
+	return "login:locked:" + email
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// handles prometheus metrics exposed on /metrics
+
+const namespace = "masstranslate"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	translationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "translation_duration_seconds",
+		Help:      "Provider translation latency by provider, request type and outcome.",
+		// documents can take minutes so the buckets go well past the http defaults
+		Buckets: []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"provider", "req_type", "result"})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Translation cache lookups by result (hit, miss, error).",
+	}, []string{"result"})
+
+	logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_logins_total",
+		Help:      "Login attempts by result (success, failure).",
+	}, []string{"result"})
+
+	providerChars = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_characters_total",
+		Help:      "Characters of text sent to translation providers.",
+	}, []string{"provider"})
+
+	providerBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_bytes_total",
+		Help:      "Bytes of documents sent to translation providers.",
+	}, []string{"provider"})
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// wraps the whole mux so every route is measured. the route label is the matched
+// mux pattern rather than the raw path so IDs in paths do not explode cardinality
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		code := strconv.Itoa(rec.status)
+		httpRequests.WithLabelValues(route, r.Method, code).Inc()
+		httpDuration.WithLabelValues(route, r.Method, code).Observe(time.Since(start).Seconds())
+	})
+}
+
+func ObserveTranslation(providerName, reqType string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	translationDuration.WithLabelValues(providerName, reqType, result).Observe(time.Since(start).Seconds())
+}
+
+func CacheHit() {
+	cacheLookups.WithLabelValues("hit").Inc()
+}
+
+func CacheMiss() {
+	cacheLookups.WithLabelValues("miss").Inc()
+}
+
+func CacheError() {
+	cacheLookups.WithLabelValues("error").Inc()
+}
+
+func LoginSucceeded() {
+	logins.WithLabelValues("success").Inc()
+}
+
+func LoginFailed() {
+	logins.WithLabelValues("failure").Inc()
+}
+
+func AddProviderUsage(providerName string, chars int, bytes int) {
+	if chars > 0 {
+		providerChars.WithLabelValues(providerName).Add(float64(chars))
+	}
+	if bytes > 0 {
+		providerBytes.WithLabelValues(providerName).Add(float64(bytes))
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status = code
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// lets http.ResponseController reach the original writer
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,318 @@
+package deeplglossary
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/mass-translate-package/format"
+	"github.com/o0n1x/mass-translate-package/lang"
+	"github.com/o0n1x/mass-translate-package/provider"
+	"github.com/o0n1x/mass-translate-package/provider/deepl"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/database"
+	"github.com/o0n1x/mass-translate-server/internal/glossary"
+)
+
+// adds glossary support on top of the mass-translate-package DeepL client, which does
+// not send glossary_id yet. our glossaries are copied to DeepL on first use and the copy is
+// recreated whenever ours is edited. the ID of the copy is kept on the glossary row so
+// restarts and other servers reuse it, DeepL limits how many glossaries an account has
+
+// how much of an error response is logged
+const maxErrorBody = 1 << 10
+
+// the glossaries table, *database.Queries
+type Store interface {
+	GetGlossary(ctx context.Context, id uuid.UUID) (database.Glossary, error)
+	SetDeepLGlossary(ctx context.Context, arg database.SetDeepLGlossaryParams) (int64, error)
+}
+
+type Client struct {
+	*deepl.DeepLClient
+	store Store
+
+	mu sync.Mutex
+	// one lock per glossary, so copying one does not hold up translations with the others
+	locks map[uuid.UUID]*sync.Mutex
+}
+
+func New(client *deepl.DeepLClient, store Store) *Client {
+	return &Client{
+		DeepLClient: client,
+		store:       store,
+		locks:       map[uuid.UUID]*sync.Mutex{},
+	}
+}
+
+func (c *Client) TranslateWithGlossary(ctx context.Context, req provider.Request, g glossary.Glossary) (provider.Response, error) {
+	// DeepL only applies glossaries when the source language is given
+	if req.From == "" || req.From == lang.AutoDetect || !deepl.SupportedFromLang[req.From] {
+		return provider.Response{}, fmt.Errorf("%s | %w : %v", provider.DeepL, apperr.ErrInvalidSourceLang, req.From)
+	}
+	if !deepl.SupportedToLang[req.To] {
+		return provider.Response{}, fmt.Errorf("%s | %w : %v", provider.DeepL, apperr.ErrInvalidTargetLang, req.To)
+	}
+
+	glossaryID, err := c.remoteGlossaryID(ctx, g)
+	if err != nil {
+		return provider.Response{}, err
+	}
+
+	switch req.ReqType {
+	case format.Text:
+		return c.translateText(ctx, req, glossaryID)
+	case format.File:
+		return c.translateDoc(ctx, req, glossaryID)
+	default:
+		return provider.Response{}, fmt.Errorf("%s | %w : request type %v", provider.DeepL, apperr.ErrInvalidRequest, req.ReqType.String())
+	}
+}
+
+func (c *Client) lock(id uuid.UUID) *sync.Mutex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.locks[id]
+	if !ok {
+		l = &sync.Mutex{}
+		c.locks[id] = l
+	}
+	return l
+}
+
+// returns the DeepL glossary for the current version of g, creating it if needed
+func (c *Client) remoteGlossaryID(ctx context.Context, g glossary.Glossary) (string, error) {
+	l := c.lock(g.ID)
+	l.Lock()
+	defer l.Unlock()
+
+	row, err := c.store.GetGlossary(ctx, g.ID)
+	if err != nil {
+		return "", fmt.Errorf("Error retrieving glossary %v: %v", g.ID, err)
+	}
+	if row.DeeplGlossaryID.Valid && row.DeeplGlossaryVersion.String == g.Version() {
+		return row.DeeplGlossaryID.String, nil
+	}
+
+	var entries strings.Builder
+	for _, entry := range g.Entries {
+		fmt.Fprintf(&entries, "%s\t%s\n", entry.Source, entry.Target)
+	}
+	params := struct {
+		Name          string `json:"name"`
+		SourceLang    string `json:"source_lang"`
+		TargetLang    string `json:"target_lang"`
+		Entries       string `json:"entries"`
+		EntriesFormat string `json:"entries_format"`
+	}{
+		Name:          g.Version(),
+		SourceLang:    strings.ToLower(glossary.BaseLanguage(g.SourceLang)),
+		TargetLang:    strings.ToLower(glossary.BaseLanguage(g.TargetLang)),
+		Entries:       entries.String(),
+		EntriesFormat: "tsv",
+	}
+
+	res := struct {
+		GlossaryID string `json:"glossary_id"`
+	}{}
+	err = c.do(ctx, http.MethodPost, "/glossaries", params, &res)
+	if err != nil {
+		return "", fmt.Errorf("Error creating DeepL glossary: %v", err)
+	}
+
+	stored, err := c.store.SetDeepLGlossary(ctx, database.SetDeepLGlossaryParams{
+		ID:                   g.ID,
+		DeeplGlossaryID:      sql.NullString{String: res.GlossaryID, Valid: true},
+		DeeplGlossaryVersion: sql.NullString{String: g.Version(), Valid: true},
+		PreviousID:           row.DeeplGlossaryID,
+	})
+	if err != nil || stored == 0 {
+		// another server stored its copy first or we could not say where ours is, either
+		// way ours would never be deleted
+		c.deleteRemote(ctx, res.GlossaryID)
+		if err != nil {
+			return "", fmt.Errorf("Error storing DeepL glossary of %v: %v", g.ID, err)
+		}
+		row, err = c.store.GetGlossary(ctx, g.ID)
+		if err != nil || row.DeeplGlossaryVersion.String != g.Version() {
+			return "", fmt.Errorf("%s | %w : glossary %v changed while it was copied", provider.DeepL, apperr.ErrTranslationFailed, g.ID)
+		}
+		return row.DeeplGlossaryID.String, nil
+	}
+
+	// the outdated copy is no longer needed
+	if row.DeeplGlossaryID.Valid {
+		c.deleteRemote(ctx, row.DeeplGlossaryID.String)
+	}
+	return res.GlossaryID, nil
+}
+
+// deletes the DeepL copy of the glossary, if it has one. called before the glossary itself
+// is deleted
+func (c *Client) DeleteGlossary(ctx context.Context, id uuid.UUID) {
+	l := c.lock(id)
+	l.Lock()
+	defer l.Unlock()
+
+	row, err := c.store.GetGlossary(ctx, id)
+	if err != nil {
+		log.Printf("Error retrieving glossary %v: %v", id, err)
+		return
+	}
+	if row.DeeplGlossaryID.Valid {
+		c.deleteRemote(ctx, row.DeeplGlossaryID.String)
+	}
+
+	c.mu.Lock()
+	delete(c.locks, id)
+	c.mu.Unlock()
+}
+
+// failing to delete a copy only leaves clutter in the DeepL account
+func (c *Client) deleteRemote(ctx context.Context, remoteID string) {
+	err := c.do(ctx, http.MethodDelete, "/glossaries/"+remoteID, nil, nil)
+	if err != nil {
+		log.Printf("Error deleting DeepL glossary %s: %v", remoteID, err)
+	}
+}
+
+func (c *Client) translateText(ctx context.Context, req provider.Request, glossaryID string) (provider.Response, error) {
+	params := struct {
+		Text       []string `json:"text"`
+		SourceLang string   `json:"source_lang"`
+		TargetLang string   `json:"target_lang"`
+		GlossaryID string   `json:"glossary_id"`
+	}{
+		Text:       req.Text,
+		SourceLang: req.From.String(),
+		TargetLang: req.To.String(),
+		GlossaryID: glossaryID,
+	}
+
+	translations := deepl.Translations{}
+	err := c.do(ctx, http.MethodPost, "/translate", params, &translations)
+	if err != nil {
+		return provider.Response{}, fmt.Errorf("Text Translation Error: %v", err)
+	}
+
+	text := []string{}
+	for _, trans := range translations.Translations {
+		text = append(text, trans.Text)
+	}
+	return provider.Response{ResType: provider.Sync, Text: text}, nil
+}
+
+// uploads the document with the glossary, status and result come from the upstream client
+func (c *Client) translateDoc(ctx context.Context, req provider.Request, glossaryID string) (provider.Response, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", req.FileName)
+	if err != nil {
+		return provider.Response{}, fmt.Errorf("Error writing file: %v", err)
+	}
+	_, err = part.Write(req.Binary)
+	if err != nil {
+		return provider.Response{}, fmt.Errorf("Error Copying File: %v", err)
+	}
+	for field, value := range map[string]string{
+		"source_lang": req.From.String(),
+		"target_lang": req.To.String(),
+		"glossary_id": glossaryID,
+	} {
+		err = writer.WriteField(field, value)
+		if err != nil {
+			return provider.Response{}, fmt.Errorf("Error writing %s to request body", field)
+		}
+	}
+	writer.Close()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL.JoinPath("/document").String(), body)
+	if err != nil {
+		return provider.Response{}, fmt.Errorf("Error creating request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", writer.FormDataContentType())
+
+	document := deepl.Documents{}
+	err = c.send(httpReq, &document)
+	if err != nil {
+		return provider.Response{}, fmt.Errorf("Document upload Error: %v", err)
+	}
+
+	res := provider.Response{ResType: provider.ASync, DocumentID: document.DocID, DocumentKey: document.DocKey}
+	for {
+		status, err := c.CheckStatus(ctx, res)
+		if err != nil {
+			return provider.Response{}, err
+		}
+		if status.Status == "done" {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return provider.Response{}, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+
+	binary, err := c.GetResult(ctx, res)
+	if err != nil {
+		return provider.Response{}, err
+	}
+	return provider.Response{ResType: provider.Sync, Binary: binary}, nil
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, params any, out any) error {
+	var body *bytes.Reader
+	if params != nil {
+		reqBody, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(reqBody)
+	} else {
+		body = bytes.NewReader(nil)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, c.BaseURL.JoinPath(path).String(), body)
+	if err != nil {
+		return err
+	}
+	if params != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	return c.send(httpReq, out)
+}
+
+func (c *Client) send(httpReq *http.Request, out any) error {
+	httpReq.Header.Set("Authorization", fmt.Sprintf("DeepL-Auth-Key %s", c.APIKey))
+
+	res, err := c.Client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	ok := http.StatusOK <= res.StatusCode && res.StatusCode < http.StatusMultipleChoices
+	if !ok {
+		// DeepL explains what was wrong in the body. it is logged here rather than returned
+		// since errors can end up in front of users
+		message, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+		log.Printf("DeepL %s %s: HTTP Error %v: %s", httpReq.Method, httpReq.URL.Path, res.StatusCode, bytes.TrimSpace(message))
+		return fmt.Errorf("HTTP Error %v", res.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
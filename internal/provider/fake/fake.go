@@ -0,0 +1,119 @@
+package fake
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/o0n1x/mass-translate-package/format"
+	"github.com/o0n1x/mass-translate-package/lang"
+	"github.com/o0n1x/mass-translate-package/provider"
+	"github.com/o0n1x/mass-translate-server/internal/apperr"
+	"github.com/o0n1x/mass-translate-server/internal/formats"
+	"github.com/o0n1x/mass-translate-server/internal/glossary"
+)
+
+// in-process provider that never touches the network, used to exercise the whole
+// translate path locally and in CI. text is returned tagged with the target language
+// e.g. "Hello" to FR becomes "[FR] Hello", files are returned with the same tag as a first line.
+// with a glossary every source term is swapped for its target term before tagging
+
+const Fake provider.Provider = "Fake"
+
+const version = "v1"
+
+func init() {
+	provider.Register(Fake, func(apiKey string) provider.Client {
+		return &FakeClient{}
+	})
+	// any file works since it is only tagged, not parsed
+	extensions := []string{}
+	for _, f := range formats.All() {
+		extensions = append(extensions, f.Extensions...)
+	}
+	formats.Support(Fake, extensions...)
+}
+
+type FakeClient struct{}
+
+func (c *FakeClient) Translate(ctx context.Context, req provider.Request) (provider.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return provider.Response{}, err
+	}
+	if req.To == "" || req.To == lang.AutoDetect {
+		return provider.Response{}, fmt.Errorf("%s | %w : %v", Fake, apperr.ErrInvalidTargetLang, req.To)
+	}
+
+	switch req.ReqType {
+	case format.Text:
+		if len(req.Text) == 0 {
+			return provider.Response{}, fmt.Errorf("%s | %w : no text", Fake, apperr.ErrInvalidRequest)
+		}
+		text := make([]string, len(req.Text))
+		for i, s := range req.Text {
+			text[i] = tag(req.To, s)
+		}
+		return provider.Response{ResType: provider.Sync, Text: text}, nil
+	case format.File:
+		if len(req.FileName) == 0 {
+			return provider.Response{}, fmt.Errorf("%s | %w : no filename", Fake, apperr.ErrInvalidRequest)
+		}
+		var binary bytes.Buffer
+		binary.WriteString(tag(req.To, "\n"))
+		binary.Write(req.Binary)
+		return provider.Response{ResType: provider.Sync, Binary: binary.Bytes()}, nil
+	default:
+		return provider.Response{}, fmt.Errorf("%s | %w : request type %v", Fake, apperr.ErrInvalidRequest, req.ReqType.String())
+	}
+}
+
+func (c *FakeClient) TranslateWithGlossary(ctx context.Context, req provider.Request, g glossary.Glossary) (provider.Response, error) {
+	// longest terms first so "New York" wins over "New"
+	entries := slices.Clone(g.Entries)
+	slices.SortFunc(entries, func(a, b glossary.Entry) int {
+		return cmp.Compare(len(b.Source), len(a.Source))
+	})
+	pairs := []string{}
+	for _, entry := range entries {
+		pairs = append(pairs, entry.Source, entry.Target)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	text := make([]string, len(req.Text))
+	for i, s := range req.Text {
+		text[i] = replacer.Replace(s)
+	}
+	req.Text = text
+	if req.Binary != nil {
+		req.Binary = []byte(replacer.Replace(string(req.Binary)))
+	}
+	return c.Translate(ctx, req)
+}
+
+func (c *FakeClient) GetCost(req provider.Request) float32 {
+	return 0
+}
+
+func (c *FakeClient) GetCharCount(req provider.Request) int {
+	totalChars := 0
+	for _, s := range req.Text {
+		totalChars += utf8.RuneCountInString(s)
+	}
+	return totalChars
+}
+
+func (c *FakeClient) Name() provider.Provider {
+	return Fake
+}
+
+func (c *FakeClient) Version() string {
+	return version
+}
+
+func tag(to lang.Language, s string) string {
+	return fmt.Sprintf("[%s] %s", to, s)
+}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// token bucket rate limiting kept in redis so every replica shares the same buckets.
+// a bucket holds Limit.Requests tokens and refills evenly over Limit.Window, so short
+// bursts are fine as long as the average stays under the limit
+
+type Limit struct {
+	Requests int64
+	Window   time.Duration
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int64
+	Remaining int64
+	// until the bucket is full again
+	Reset time.Duration
+	// until the next request would be allowed, zero if this one was
+	RetryAfter time.Duration
+}
+
+type Limiter struct {
+	redis *redis.Client
+}
+
+func New(client *redis.Client) *Limiter {
+	return &Limiter{redis: client}
+}
+
+// takes a token from the bucket in one step. the time comes from redis so replicas with
+// drifting clocks still agree
+var takeToken = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'at')
+local tokens = tonumber(bucket[1]) or capacity
+local at = tonumber(bucket[2]) or now
+tokens = math.min(capacity, tokens + (now - at) * capacity / window)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) * window / capacity)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'at', tostring(now))
+redis.call('PEXPIRE', KEYS[1], window)
+return {allowed, math.floor(tokens), math.ceil((capacity - tokens) * window / capacity), retry}
+`)
+
+// counts a request against the bucket of key, e.g. user:<id>
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	values, err := takeToken.Run(ctx, l.redis, []string{"ratelimit:" + key}, limit.Requests, limit.Window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit.Requests,
+		Remaining:  values[1],
+		Reset:      time.Duration(values[2]) * time.Millisecond,
+		RetryAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
+
+// sets the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, and
+// Retry-After when the request was refused. times are whole seconds rounded up
+func (r Result) WriteHeaders(h http.Header) {
+	h.Set("RateLimit-Limit", strconv.FormatInt(r.Limit, 10))
+	h.Set("RateLimit-Remaining", strconv.FormatInt(r.Remaining, 10))
+	h.Set("RateLimit-Reset", strconv.FormatInt(seconds(r.Reset), 10))
+	if !r.Allowed {
+		h.Set("Retry-After", strconv.FormatInt(max(seconds(r.RetryAfter), 1), 10))
+	}
+}
+
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
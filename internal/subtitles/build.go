@@ -0,0 +1,224 @@
+package subtitles
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var markupPattern = regexp.MustCompile(`<[^>]*>|\{\\[^}]*\}`)
+
+// voice, class, language, ruby and timestamp tags SRT players do not know
+var vttOnlyMarkup = regexp.MustCompile(`</?(?:v|c|lang|ruby|rt)(?:[ .][^>]*)?>|<\d[\d:.]*>`)
+
+// the file with the translations of Texts in place of the cue text
+func (f *File) Build(translations []string, opts Options) ([]byte, error) {
+	if len(translations) != len(f.texts) {
+		return nil, fmt.Errorf("got %d translations for %d texts", len(translations), len(f.texts))
+	}
+
+	// the translated lines of each cue, nil for cues left as they are. a text that comes back
+	// unchanged keeps its cues as they were, line breaks included
+	lines := make([][]string, len(f.Cues))
+	for i, u := range f.units {
+		if translations[i] == f.texts[i] {
+			continue
+		}
+		if u.line >= 0 {
+			c := u.cues[0]
+			if lines[c] == nil {
+				lines[c] = make([]string, len(f.Cues[c].Lines))
+				copy(lines[c], f.Cues[c].Lines)
+			}
+			lines[c][u.line] = strings.TrimSpace(translations[i])
+			continue
+		}
+
+		lengths := make([]int, len(u.cues))
+		for j, c := range u.cues {
+			_, text, _ := peel(f.Cues[c].Lines)
+			lengths[j] = utf8.RuneCountInString(text)
+		}
+		for j, text := range splitText(strings.TrimSpace(translations[i]), lengths) {
+			cue := f.Cues[u.cues[j]]
+			opening, _, closing := peel(cue.Lines)
+			lines[u.cues[j]] = layout(opening+text+closing, len(cue.Lines), opts.MaxLineLength)
+		}
+	}
+	// dialogue lines keep their own line but are wrapped too
+	if opts.MaxLineLength > 0 {
+		for c, cueLines := range lines {
+			if cueLines == nil || !isDialogue(f.Cues[c].Lines) {
+				continue
+			}
+			wrapped := []string{}
+			for _, l := range cueLines {
+				wrapped = append(wrapped, wrap(l, opts.MaxLineLength)...)
+			}
+			lines[c] = wrapped
+		}
+	}
+
+	if opts.Format == "" || opts.Format == f.Format {
+		return f.replace(lines), nil
+	}
+	return f.convert(lines, opts.Format), nil
+}
+
+// the original file with only the text of the translated cues replaced
+func (f *File) replace(lines [][]string) []byte {
+	var out bytes.Buffer
+	last := 0
+	for c, cue := range f.Cues {
+		if lines[c] == nil {
+			continue
+		}
+		out.Write(f.data[last:cue.start])
+		out.WriteString(strings.Join(lines[c], f.newline))
+		last = cue.end
+	}
+	out.Write(f.data[last:])
+	return out.Bytes()
+}
+
+// the file written in the other format. converting to SRT numbers the cues and drops WebVTT
+// cue settings and blocks such as NOTE or STYLE
+func (f *File) convert(lines [][]string, to Format) []byte {
+	var out bytes.Buffer
+	nl := f.newline
+	if to == VTT {
+		out.WriteString("WEBVTT" + nl + nl)
+	}
+	for c, cue := range f.Cues {
+		text := cue.Lines
+		if lines[c] != nil {
+			text = lines[c]
+		}
+		if to == SRT {
+			fmt.Fprintf(&out, "%d%s%s --> %s%s", c+1, nl, srtTimestamp(cue.Start), srtTimestamp(cue.End), nl)
+		} else {
+			if cue.ID != "" {
+				out.WriteString(cue.ID + nl)
+			}
+			fmt.Fprintf(&out, "%s --> %s%s", vttTimestamp(cue.Start), vttTimestamp(cue.End), nl)
+		}
+		for _, l := range text {
+			if to == SRT {
+				l = vttOnlyMarkup.ReplaceAllString(l, "")
+			}
+			out.WriteString(l + nl)
+		}
+		out.WriteString(nl)
+	}
+	return out.Bytes()
+}
+
+// SRT always has hours and a comma before the milliseconds
+func srtTimestamp(t string) string {
+	return timestamp(t, ",")
+}
+
+func vttTimestamp(t string) string {
+	return timestamp(t, ".")
+}
+
+// hh:mm:ss followed by separator and three digits of milliseconds. the parser also takes
+// one digit minutes and fewer digits of milliseconds, as in 1:02.5
+func timestamp(t string, separator string) string {
+	clock, fraction, _ := strings.Cut(strings.Replace(t, ",", ".", 1), ".")
+	parts := strings.Split(clock, ":")
+	if len(parts) == 2 {
+		parts = append([]string{"0"}, parts...)
+	}
+	for i, part := range parts {
+		if len(part) < 2 {
+			parts[i] = strings.Repeat("0", 2-len(part)) + part
+		}
+	}
+	return strings.Join(parts, ":") + separator + fraction + strings.Repeat("0", 3-len(fraction))
+}
+
+// splits the translation of cues joined into one text back over them, in proportion to the
+// length each cue had. markup does not count toward the length. words are kept whole unless there are fewer of them than cues, as in
+// languages written without spaces
+func splitText(text string, lengths []int) []string {
+	if len(lengths) == 1 {
+		return []string{text}
+	}
+	total := 0
+	for _, l := range lengths {
+		total += max(l, 1)
+	}
+
+	words := strings.Fields(text)
+	separator := " "
+	if len(words) < len(lengths) {
+		words = strings.Split(text, "")
+		separator = ""
+	}
+	size := 0
+	for _, w := range words {
+		size += visibleLength(w)
+	}
+
+	parts := make([]string, 0, len(lengths))
+	next, done, share := 0, 0, 0
+	for i, l := range lengths[:len(lengths)-1] {
+		share += max(l, 1)
+		target := size * share / total
+		start := next
+		// every cue gets at least one word and leaves one for each cue after it
+		for next < len(words)-(len(lengths)-1-i) && (next == start || done+visibleLength(words[next])/2 < target) {
+			done += visibleLength(words[next])
+			next++
+		}
+		parts = append(parts, strings.Join(words[start:next], separator))
+	}
+	return append(parts, strings.Join(words[next:], separator))
+}
+
+// the text of a cue on as many lines as it had, or wrapped to maxLength characters
+func layout(text string, lines int, maxLength int) []string {
+	if maxLength > 0 {
+		return wrap(text, maxLength)
+	}
+	return balance(text, max(lines, 1))
+}
+
+// greedy word wrap, a word longer than maxLength gets a line of its own. markup does not
+// count toward the length
+func wrap(text string, maxLength int) []string {
+	lines := []string{}
+	current := ""
+	for _, word := range strings.Fields(text) {
+		if current != "" && visibleLength(current)+1+visibleLength(word) > maxLength {
+			lines = append(lines, current)
+			current = ""
+		}
+		if current != "" {
+			current += " "
+		}
+		current += word
+	}
+	return append(lines, current)
+}
+
+func visibleLength(s string) int {
+	return utf8.RuneCountInString(markupPattern.ReplaceAllString(s, ""))
+}
+
+// breaks text into n lines of about the same length at spaces
+func balance(text string, n int) []string {
+	words := strings.Fields(text)
+	if n == 1 || len(words) <= 1 {
+		return []string{text}
+	}
+	n = min(n, len(words))
+	lengths := make([]int, n)
+	for i := range lengths {
+		lengths[i] = 1
+	}
+	return splitText(text, lengths)
+}
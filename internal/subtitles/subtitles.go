@@ -0,0 +1,283 @@
+package subtitles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// translates SubRip and WebVTT subtitles on the server. cues are parsed out of the file and
+// only their text is translated, cue numbers, timings and anything else in the file are kept
+// byte for byte. a sentence running over a few cues is translated as one text so the provider
+// sees it whole, then split back over the cues
+
+type Format string
+
+const (
+	SRT Format = "srt"
+	VTT Format = "vtt"
+)
+
+// the most cues translated together as one sentence
+const maxGroupCues = 4
+
+// 00:01:02,500 --> 00:01:04,000 in SRT, 01:02.500 --> 01:04.000 align:start in WebVTT
+var timingPattern = regexp.MustCompile(`^\s*((?:\d+:)?\d{1,2}:\d{2}[,.]\d{1,3})\s*-->\s*((?:\d+:)?\d{1,2}:\d{2}[,.]\d{1,3})(.*)$`)
+
+// markup around the whole text of a cue, such as <i>...</i> or an {\an8} position tag
+var (
+	openingMarkup = regexp.MustCompile(`^(?:<[^/>][^>]*>|\{\\[^}]*\})+`)
+	closingMarkup = regexp.MustCompile(`(?:</[^>]+>)+$`)
+)
+
+type Cue struct {
+	// the cue number in SRT, the optional identifier in WebVTT
+	ID    string
+	Start string
+	End   string
+	// WebVTT cue settings such as align:start, dropped when converting to SRT
+	Settings string
+	Lines    []string
+	// where the lines are in the file, used to put the translation back in place
+	start int
+	end   int
+}
+
+// a text sent to the provider and the cues it came from
+type unit struct {
+	cues []int
+	// the dialogue line of the cue, -1 for the whole cue
+	line int
+}
+
+type File struct {
+	Format Format
+	Cues   []Cue
+	data   []byte
+	// \n or \r\n, whichever the file uses
+	newline string
+	// WebVTT blocks that are not cues, such as the header, NOTE or STYLE
+	blocks []string
+	units  []unit
+	texts  []string
+}
+
+type Options struct {
+	// wraps translated cues to lines of at most this many characters, 0 keeps the number of
+	// lines each cue had
+	MaxLineLength int
+	// the format of the translated file, empty keeps the format of the original
+	Format Format
+}
+
+// the format of a file by its extension
+func FormatOf(filename string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".srt":
+		return SRT, true
+	case ".vtt":
+		return VTT, true
+	}
+	return "", false
+}
+
+func Supports(filename string) bool {
+	_, ok := FormatOf(filename)
+	return ok
+}
+
+// the file name with the extension of the format it is converted to, other files keep their name
+func OutputName(filename string, opts Options) string {
+	if opts.Format == "" || !Supports(filename) {
+		return filename
+	}
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + "." + string(opts.Format)
+}
+
+func Parse(filename string, data []byte) (*File, error) {
+	format, ok := FormatOf(filename)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a subtitle file", filename)
+	}
+	f := &File{Format: format, data: data, newline: "\n"}
+	if bytes.Contains(data, []byte("\r\n")) {
+		f.newline = "\r\n"
+	}
+	if !utf8.Valid(data) {
+		return nil, errors.New("subtitles are not UTF-8")
+	}
+
+	blocks := splitBlocks(data)
+	if format == VTT && (len(blocks) == 0 || !strings.HasPrefix(strings.TrimPrefix(blocks[0].lines[0].text, "\uFEFF"), "WEBVTT")) {
+		return nil, errors.New("WebVTT file without a WEBVTT header")
+	}
+	for _, b := range blocks {
+		cue, ok := b.cue()
+		if !ok {
+			if format == SRT {
+				return nil, fmt.Errorf("line %d: expected a cue timing", b.lines[0].number)
+			}
+			f.blocks = append(f.blocks, string(data[b.lines[0].start:b.lines[len(b.lines)-1].end]))
+			continue
+		}
+		f.Cues = append(f.Cues, cue)
+	}
+	f.split()
+	return f, nil
+}
+
+type line struct {
+	text   string
+	number int
+	// where the text is in the file, without the line break
+	start int
+	end   int
+}
+
+type block struct {
+	lines []line
+}
+
+// the groups of lines between blank lines
+func splitBlocks(data []byte) []block {
+	blocks := []block{}
+	current := block{}
+	for i, number := 0, 1; i < len(data); number++ {
+		end := bytes.IndexByte(data[i:], '\n')
+		next := i + end + 1
+		if end < 0 {
+			end, next = len(data)-i, len(data)
+		}
+		l := line{text: string(data[i : i+end]), number: number, start: i, end: i + end}
+		if strings.HasSuffix(l.text, "\r") {
+			l.text, l.end = l.text[:len(l.text)-1], l.end-1
+		}
+		i = next
+
+		if strings.TrimSpace(l.text) == "" {
+			if len(current.lines) > 0 {
+				blocks = append(blocks, current)
+			}
+			current = block{}
+			continue
+		}
+		current.lines = append(current.lines, l)
+	}
+	if len(current.lines) > 0 {
+		blocks = append(blocks, current)
+	}
+	return blocks
+}
+
+// a block is a cue when its first or second line is a timing, the first being the ID
+func (b block) cue() (Cue, bool) {
+	for t := 0; t < min(2, len(b.lines)); t++ {
+		match := timingPattern.FindStringSubmatch(b.lines[t].text)
+		if match == nil {
+			continue
+		}
+		cue := Cue{Start: match[1], End: match[2], Settings: strings.TrimSpace(match[3]), start: -1}
+		if t == 1 {
+			cue.ID = strings.TrimSpace(b.lines[0].text)
+		}
+		for _, l := range b.lines[t+1:] {
+			cue.Lines = append(cue.Lines, l.text)
+		}
+		if len(cue.Lines) > 0 {
+			cue.start, cue.end = b.lines[t+1].start, b.lines[len(b.lines)-1].end
+		}
+		return cue, true
+	}
+	return Cue{}, false
+}
+
+// the texts to translate, in the order of the cues
+func (f *File) Texts() []string {
+	return f.texts
+}
+
+// splits the cues into the texts sent to the provider. lines of a dialogue, each starting
+// with a dash, are translated on their own. other cues are joined with the following ones
+// until a sentence ends
+func (f *File) split() {
+	group := unit{line: -1}
+	flush := func() {
+		if len(group.cues) == 0 {
+			return
+		}
+		texts := []string{}
+		for _, c := range group.cues {
+			_, text, _ := peel(f.Cues[c].Lines)
+			texts = append(texts, text)
+		}
+		f.units = append(f.units, group)
+		f.texts = append(f.texts, strings.Join(texts, " "))
+		group = unit{line: -1}
+	}
+
+	for i, cue := range f.Cues {
+		if len(cue.Lines) == 0 {
+			continue
+		}
+		if isDialogue(cue.Lines) {
+			flush()
+			for l, text := range cue.Lines {
+				f.units = append(f.units, unit{cues: []int{i}, line: l})
+				f.texts = append(f.texts, text)
+			}
+			continue
+		}
+		_, text, _ := peel(cue.Lines)
+		if !strings.ContainsFunc(text, unicode.IsLetter) {
+			flush()
+			continue
+		}
+		if hasMarkup(text) {
+			flush()
+			group.cues = []int{i}
+			flush()
+			continue
+		}
+		group.cues = append(group.cues, i)
+		if endsSentence(text) || len(group.cues) == maxGroupCues {
+			flush()
+		}
+	}
+	flush()
+}
+
+func isDialogue(lines []string) bool {
+	if len(lines) < 2 {
+		return false
+	}
+	for _, l := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(l), "-") {
+			return false
+		}
+	}
+	return true
+}
+
+// the markup around the whole text of a cue and the text on one line
+func peel(lines []string) (string, string, string) {
+	text := strings.Join(lines, " ")
+	opening := openingMarkup.FindString(text)
+	text = text[len(opening):]
+	closing := closingMarkup.FindString(text)
+	return opening, strings.TrimSpace(text[:len(text)-len(closing)]), closing
+}
+
+func hasMarkup(text string) bool {
+	return strings.Contains(text, "<") || strings.Contains(text, `{\`)
+}
+
+func endsSentence(text string) bool {
+	text = strings.TrimRight(text, " \"'”’»)]")
+	last, _ := utf8.DecodeLastRuneInString(text)
+	return strings.ContainsRune(".!?…。！？♪", last)
+}
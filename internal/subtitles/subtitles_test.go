@@ -0,0 +1,251 @@
+package subtitles
+
+import (
+	"strings"
+	"testing"
+)
+
+const srtFile = `1
+00:00:01,000 --> 00:00:02,500
+Hello there,
+my old friend.
+
+2
+00:00:03,000 --> 00:00:04,000
+<i>It has been</i>
+
+3
+00:00:04,200 --> 00:00:06,000
+a long time
+since we met
+
+4
+00:00:07,000 --> 00:00:08,000
+- Who are you?
+- Nobody.
+
+5
+00:00:09,000 --> 00:00:10,000
+♪
+
+`
+
+const vttFile = `WEBVTT - a test
+
+NOTE made by hand,
+over two lines
+
+STYLE
+::cue { color: yellow }
+
+intro
+00:01.000 --> 00:02.500 align:start position:10%
+{\an8}Hello there,
+my old friend.
+
+1:03.000 --> 1:04.000
+<v Anna>It has been a long time</v>
+
+01:00:04.200 --> 01:00:06.000
+since we met.
+`
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		data     string
+	}{
+		{"srt", "a.srt", srtFile},
+		{"srt with CRLF", "a.srt", strings.ReplaceAll(srtFile, "\n", "\r\n")},
+		{"srt without trailing newline", "a.srt", strings.TrimRight(srtFile, "\n")},
+		{"vtt", "a.vtt", vttFile},
+		{"vtt with BOM", "a.vtt", "\uFEFF" + vttFile},
+		{"cue without text", "a.srt", "1\n00:00:01,000 --> 00:00:02,000\n\n2\n00:00:03,000 --> 00:00:04,000\nHi.\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse(tt.filename, []byte(tt.data))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			out, err := f.Build(f.Texts(), Options{})
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+			if string(out) != tt.data {
+				t.Errorf("Build with the original texts changed the file\ngot:\n%q\nwant:\n%q", out, tt.data)
+			}
+		})
+	}
+}
+
+// everything but the cue text is kept byte for byte
+func TestTranslationKeepsTimings(t *testing.T) {
+	for _, tt := range []struct{ filename, data string }{{"a.srt", srtFile}, {"a.vtt", vttFile}} {
+		f, err := Parse(tt.filename, []byte(tt.data))
+		if err != nil {
+			t.Fatalf("%s: Parse: %v", tt.filename, err)
+		}
+		translations := []string{}
+		for _, text := range f.Texts() {
+			translations = append(translations, strings.ToUpper(text))
+		}
+		// markup around a cue is kept, only the text inside is translated
+		upper := func(lines []string) string {
+			opening, text, closing := peel(lines)
+			return opening + strings.ToUpper(text) + closing
+		}
+		out, err := f.Build(translations, Options{})
+		if err != nil {
+			t.Fatalf("%s: Build: %v", tt.filename, err)
+		}
+
+		translated, err := Parse(tt.filename, out)
+		if err != nil {
+			t.Fatalf("%s: Parse of the translation: %v", tt.filename, err)
+		}
+		if len(translated.Cues) != len(f.Cues) {
+			t.Fatalf("%s: got %d cues, want %d", tt.filename, len(translated.Cues), len(f.Cues))
+		}
+		for i, cue := range f.Cues {
+			got := translated.Cues[i]
+			if got.ID != cue.ID || got.Start != cue.Start || got.End != cue.End || got.Settings != cue.Settings {
+				t.Errorf("%s: cue %d is %q %s --> %s %q, want %q %s --> %s %q", tt.filename, i, got.ID, got.Start, got.End, got.Settings, cue.ID, cue.Start, cue.End, cue.Settings)
+			}
+			if len(cue.Lines) > 0 && strings.Join(got.Lines, " ") != upper(cue.Lines) {
+				t.Errorf("%s: cue %d reads %q", tt.filename, i, got.Lines)
+			}
+		}
+		if !strings.Contains(string(out), "NOTE made by hand,\nover two lines") && tt.filename == "a.vtt" {
+			t.Errorf("%s: NOTE block was not kept", tt.filename)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	f, err := Parse("a.srt", []byte(srtFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cue 2 is joined with 3 since markup around a whole cue is put back on its own,
+	// the music note has no words to translate
+	want := []string{
+		"Hello there, my old friend.",
+		"It has been a long time since we met",
+		"- Who are you?",
+		"- Nobody.",
+	}
+	got := f.Texts()
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Texts() = %q, want %q", got, want)
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	tests := []struct {
+		in  string
+		srt string
+		vtt string
+	}{
+		{"00:00:01,000", "00:00:01,000", "00:00:01.000"},
+		{"01:02.500", "00:01:02,500", "00:01:02.500"},
+		{"1:02.500", "00:01:02,500", "00:01:02.500"},
+		{"1:02:03.5", "01:02:03,500", "01:02:03.500"},
+		{"123:04:05.678", "123:04:05,678", "123:04:05.678"},
+	}
+	for _, tt := range tests {
+		if got := srtTimestamp(tt.in); got != tt.srt {
+			t.Errorf("srtTimestamp(%q) = %q, want %q", tt.in, got, tt.srt)
+		}
+		if got := vttTimestamp(tt.in); got != tt.vtt {
+			t.Errorf("vttTimestamp(%q) = %q, want %q", tt.in, got, tt.vtt)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	f, err := Parse("a.vtt", []byte(vttFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.Build(f.Texts(), Options{Format: SRT})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `1
+00:00:01,000 --> 00:00:02,500
+{\an8}Hello there,
+my old friend.
+
+2
+00:01:03,000 --> 00:01:04,000
+It has been a long time
+
+3
+01:00:04,200 --> 01:00:06,000
+since we met.
+
+`
+	if string(out) != want {
+		t.Errorf("VTT to SRT\ngot:\n%s\nwant:\n%s", out, want)
+	}
+
+	srt, err := Parse("a.srt", out)
+	if err != nil {
+		t.Fatalf("the converted file does not parse as SRT: %v", err)
+	}
+	back, err := srt.Build(srt.Texts(), Options{Format: VTT})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the SRT cue numbers become cue identifiers
+	if !strings.HasPrefix(string(back), "WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.500\n") {
+		t.Errorf("SRT to VTT starts with %q", string(back)[:min(len(back), 60)])
+	}
+	if _, err := Parse("a.vtt", back); err != nil {
+		t.Errorf("the converted file does not parse as WebVTT: %v", err)
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		text    string
+		lengths []int
+		want    []string
+	}{
+		{"one two three four", []int{9, 9}, []string{"one two", "three four"}},
+		{"one two three four", []int{3, 15}, []string{"one", "two three four"}},
+		// every cue gets a word even when the proportions say otherwise
+		{"one two", []int{1, 1, 100}, []string{"o", "n", "e two"}},
+		{"私は元気です", []int{3, 3}, []string{"私は元", "気です"}},
+	}
+	for _, tt := range tests {
+		got := splitText(tt.text, tt.lengths)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("splitText(%q, %v) = %q, want %q", tt.text, tt.lengths, got, tt.want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	got := wrap("<i>a few words that do not fit</i>", 12)
+	want := []string{"<i>a few words", "that do not", "fit</i>"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("wrap = %q, want %q", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct{ filename, data string }{
+		{"a.srt", "1\nnot a timing\nHello\n"},
+		{"a.vtt", "00:01.000 --> 00:02.000\nHello\n"},
+		{"a.srt", "1\n00:00:01,000 --> 00:00:02,000\n\xff\n"},
+		{"a.txt", "Hello\n"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.filename, []byte(tt.data)); err == nil {
+			t.Errorf("Parse(%s, %q) did not fail", tt.filename, tt.data)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// reads and writes TMX, the XML format translation memories are exchanged in. only the
+// plain text of segments is kept, inline markup such as <bpt> or <ph> is dropped on import
+
+// wraps every error about the content of the file
+var ErrInvalid = errors.New("invalid TMX")
+
+// a source segment and its translation
+type Unit struct {
+	SourceLang string
+	TargetLang string
+	Source     string
+	Target     string
+}
+
+type header struct {
+	CreationTool        string `xml:"creationtool,attr"`
+	CreationToolVersion string `xml:"creationtoolversion,attr"`
+	SegType             string `xml:"segtype,attr"`
+	OTMF                string `xml:"o-tmf,attr"`
+	AdminLang           string `xml:"adminlang,attr"`
+	SrcLang             string `xml:"srclang,attr"`
+	DataType            string `xml:"datatype,attr"`
+}
+
+type tu struct {
+	XMLName xml.Name `xml:"tu"`
+	SrcLang string   `xml:"srclang,attr,omitempty"`
+	Tuvs    []tuv    `xml:"tuv"`
+}
+
+type tuv struct {
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	// TMX 1.1 has a plain lang attribute, only read
+	OldLang string `xml:"lang,attr,omitempty"`
+	Seg     struct {
+		Text string `xml:",chardata"`
+	} `xml:"seg"`
+}
+
+// reads every translation unit and calls fn with one Unit per target language. the source
+// of a unit is its tuv in the unit's or the header's srclang. languages are upper case
+func Read(r io.Reader, fn func(Unit) error) error {
+	decoder := xml.NewDecoder(r)
+	srcLang := ""
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalid, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "header":
+			h := header{}
+			err = decoder.DecodeElement(&h, &start)
+			if err != nil {
+				return fmt.Errorf("%w header: %w", ErrInvalid, err)
+			}
+			srcLang = h.SrcLang
+		case "tu":
+			unit := tu{}
+			err = decoder.DecodeElement(&unit, &start)
+			if err != nil {
+				return fmt.Errorf("%w unit: %w", ErrInvalid, err)
+			}
+			err = readUnit(unit, srcLang, fn)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func readUnit(unit tu, srcLang string, fn func(Unit) error) error {
+	for i, v := range unit.Tuvs {
+		if v.Lang == "" {
+			unit.Tuvs[i].Lang = v.OldLang
+		}
+		if unit.Tuvs[i].Lang == "" {
+			return fmt.Errorf("%w: segment without a language", ErrInvalid)
+		}
+	}
+	if unit.SrcLang != "" {
+		srcLang = unit.SrcLang
+	}
+	// *all* means any language can be the source, the first one is taken
+	if (srcLang == "" || srcLang == "*all*") && len(unit.Tuvs) > 0 {
+		srcLang = unit.Tuvs[0].Lang
+	}
+
+	source := -1
+	for i, v := range unit.Tuvs {
+		if strings.EqualFold(v.Lang, srcLang) {
+			source = i
+			break
+		}
+	}
+	if source < 0 {
+		return fmt.Errorf("%w: unit without a %s segment", ErrInvalid, srcLang)
+	}
+
+	for i, v := range unit.Tuvs {
+		if i == source {
+			continue
+		}
+		err := fn(Unit{
+			SourceLang: strings.ToUpper(unit.Tuvs[source].Lang),
+			TargetLang: strings.ToUpper(v.Lang),
+			Source:     unit.Tuvs[source].Seg.Text,
+			Target:     v.Seg.Text,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writes units as they come, call Close to finish the document
+type Writer struct {
+	encoder *xml.Encoder
+}
+
+// srcLang is the source language of every unit, or *all* when they differ
+func NewWriter(w io.Writer, srcLang string) (*Writer, error) {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return nil, err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	root := xml.StartElement{
+		Name: xml.Name{Local: "tmx"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "version"}, Value: "1.4"}},
+	}
+	err = encoder.EncodeToken(root)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.EncodeElement(header{
+		CreationTool:        "mass-translate-server",
+		CreationToolVersion: "1",
+		SegType:             "sentence",
+		OTMF:                "mass-translate-server",
+		AdminLang:           "EN",
+		SrcLang:             srcLang,
+		DataType:            "plaintext",
+	}, xml.StartElement{Name: xml.Name{Local: "header"}})
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.EncodeToken(xml.StartElement{Name: xml.Name{Local: "body"}})
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{encoder: encoder}, nil
+}
+
+func (w *Writer) Write(u Unit) error {
+	unit := tu{SrcLang: u.SourceLang, Tuvs: make([]tuv, 2)}
+	unit.Tuvs[0].Lang, unit.Tuvs[0].Seg.Text = u.SourceLang, u.Source
+	unit.Tuvs[1].Lang, unit.Tuvs[1].Seg.Text = u.TargetLang, u.Target
+	return w.encoder.Encode(unit)
+}
+
+func (w *Writer) Close() error {
+	err := w.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: "body"}})
+	if err != nil {
+		return err
+	}
+	err = w.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: "tmx"}})
+	if err != nil {
+		return err
+	}
+	return w.encoder.Flush()
+}
@@ -5,29 +5,38 @@ import (
 	"database/sql"
 	"log"
 	"net/http"
-	"os"
+	"strings"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/o0n1x/mass-translate-package/format"
+	"github.com/o0n1x/mass-translate-package/provider"
 	"github.com/o0n1x/mass-translate-server/internal/api"
+	"github.com/o0n1x/mass-translate-server/internal/auth"
+	"github.com/o0n1x/mass-translate-server/internal/cache"
+	"github.com/o0n1x/mass-translate-server/internal/config"
 	"github.com/o0n1x/mass-translate-server/internal/database"
+	"github.com/o0n1x/mass-translate-server/internal/lockout"
+	"github.com/o0n1x/mass-translate-server/internal/metrics"
+	"github.com/o0n1x/mass-translate-server/internal/provider/fake"
+	"github.com/o0n1x/mass-translate-server/internal/ratelimit"
 	"github.com/redis/go-redis/v9"
 )
 
 func main() {
 
 	godotenv.Load()
-	dbURL := os.Getenv("DB_URL")
-	//secret := os.Getenv("SECRET_JWT")
-	deeplAPI := os.Getenv("DEEPL_API")
+	conf, err := config.Load()
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
 	filepathRoot := "/app/"
-	port := "8080"
-	db, err := sql.Open("postgres", dbURL)
+	db, err := sql.Open("postgres", conf.DBURL)
 	if err != nil {
 		log.Fatalf("Error connecting to PostgreSQL DB: %v", err)
 	}
 	rdb := redis.NewClient(&redis.Options{
-		Addr: os.Getenv("REDIS_URL"),
+		Addr: conf.RedisURL,
 	})
 
 	err = rdb.Ping(context.Background()).Err()
@@ -38,33 +47,136 @@ func main() {
 	dbms := database.New(db)
 	cfg := api.ApiConfig{}
 	cfg.DB = dbms
-	cfg.DeeplClientAPI = deeplAPI
+	cfg.DBConn = db
 	cfg.Redis = rdb
-	cfg.AdminCredentials.Email = os.Getenv("ADMIN_EMAIL")
-	cfg.AdminCredentials.Password = os.Getenv("ADMIN_PASSWORD")
+	cfg.SECRET_JWT = conf.JWTSecret
+	cfg.TokenTTL = conf.TokenTTL
+	cfg.RefreshTokenTTL = conf.RefreshTokenTTL
+	cfg.MaxFileSize = conf.MaxFileSize
+	cfg.Lockout = lockout.New(rdb, conf.LoginMaxFailures, conf.LoginLockout)
+	cfg.RateLimiter = ratelimit.New(rdb)
+	cfg.RateLimit = conf.RateLimit
+	cfg.IPRateLimit = conf.IPRateLimit
+	// already validated by config.Load
+	cfg.TrustedProxies, _ = conf.Proxies()
+	cfg.TMThreshold = float32(conf.TMThreshold)
+	cfg.TMRecord = conf.TMRecord
+	cfg.AdminCredentials.Email = conf.Admin.Email
+	cfg.AdminCredentials.Password = conf.Admin.Password
+	cache.TTLs[format.Text] = conf.CacheTTL
+	cache.TTLs[format.File] = conf.CacheFileTTL
+	cache.LargeFileTTL = conf.CacheLargeFileTTL
+	cache.LargeFileSize = conf.CacheLargeFileSize
+	cache.MaxEntrySize = conf.CacheMaxEntrySize
+	cache.MaxSize = conf.CacheMaxSize
+
+	// providers are enabled by name, e.g. PROVIDERS=deepl,fake
+	knownProviders := []provider.Provider{provider.DeepL, fake.Fake}
+	for _, name := range conf.Providers {
+		enabled := false
+		for _, providerName := range knownProviders {
+			if strings.EqualFold(string(providerName), name) {
+				err = cfg.EnableProvider(providerName, conf.ProviderKey(name), conf.Extensions(name))
+				if err != nil {
+					log.Fatalf("Error enabling provider %s: %v", providerName, err)
+				}
+				enabled = true
+			}
+		}
+		if !enabled {
+			log.Fatalf("Error unknown provider in PROVIDERS: %s", name)
+		}
+	}
+
+	// providers tried in order when the requested one fails, e.g. FALLBACK_PROVIDERS=fake
+	err = cfg.SetFallback(conf.FallbackProviders)
+	if err != nil {
+		log.Fatalf("Error setting fallback providers: %v", err)
+	}
 
 	//register admin
 	cfg.RegisterAdmin()
 
+	// documents left translating by a server that stopped would otherwise never finish
+	go cfg.FailStaleDocuments()
+
 	mux := http.NewServeMux()
 
 	mux.Handle(filepathRoot, http.StripPrefix("/app/", http.FileServer(http.Dir("."))))
 
 	mux.HandleFunc("GET /api/health", api.HealthCheck)
-	mux.HandleFunc("POST /api/deepl/translate", cfg.MiddlewareIsUser(cfg.DeeplTranslate))
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.HandleFunc("GET /api/providers", cfg.MiddlewarePermission(auth.PermTranslate, cfg.GetProviders))
+	mux.HandleFunc("GET /api/formats", cfg.MiddlewarePermission(auth.PermTranslate, cfg.GetFormats))
+	mux.HandleFunc("POST /api/{provider}/translate", cfg.MiddlewarePermission(auth.PermTranslate, cfg.Translate))
+	mux.HandleFunc("POST /api/{provider}/documents", cfg.MiddlewarePermission(auth.PermTranslate, cfg.CreateDocument))
+	mux.HandleFunc("GET /api/{provider}/documents/{id}", cfg.MiddlewarePermission(auth.PermTranslate, cfg.GetDocument))
+	mux.HandleFunc("GET /api/{provider}/documents/{id}/download", cfg.MiddlewarePermission(auth.PermTranslate, cfg.DownloadDocument))
+	mux.HandleFunc("DELETE /api/{provider}/documents/{id}", cfg.MiddlewarePermission(auth.PermTranslate, cfg.DeleteDocument))
 	mux.HandleFunc("POST /api/auth/login", cfg.Login)
-	mux.HandleFunc("POST /api/admin/users", cfg.MiddlewareIsAdmin(cfg.Register))
-	mux.HandleFunc("GET /api/admin/users", cfg.MiddlewareIsAdmin(cfg.GetUsers))
-	mux.HandleFunc("GET /api/admin/users/{id}", cfg.MiddlewareIsAdmin(cfg.GetUsers))
-	mux.HandleFunc("DELETE /api/admin/users/{id}", cfg.MiddlewareIsAdmin(cfg.DeleteUser))
-	mux.HandleFunc("PUT /api/admin/users/{id}", cfg.MiddlewareIsAdmin(cfg.UpdateUser))
+	mux.HandleFunc("POST /api/auth/refresh", cfg.Refresh)
+	mux.HandleFunc("POST /api/auth/logout", cfg.Logout)
+	mux.HandleFunc("GET /api/me", cfg.MiddlewareIsUser(cfg.GetMe))
+	mux.HandleFunc("PUT /api/me/password", cfg.MiddlewareIsUser(cfg.ChangeMyPassword))
+	mux.HandleFunc("GET /api/me/history", cfg.MiddlewareIsUser(cfg.GetMyHistory))
+	mux.HandleFunc("GET /api/me/usage", cfg.MiddlewareIsUser(cfg.GetMyUsage))
+	mux.HandleFunc("GET /api/me/api-keys", cfg.MiddlewareIsUser(cfg.GetMyAPIKeys))
+	mux.HandleFunc("POST /api/me/api-keys", cfg.MiddlewareIsUser(cfg.CreateMyAPIKey))
+	mux.HandleFunc("DELETE /api/me/api-keys/{key_id}", cfg.MiddlewareIsUser(cfg.DeleteMyAPIKey))
+	mux.HandleFunc("POST /api/admin/users", cfg.MiddlewarePermission(auth.PermUsersWrite, cfg.Register))
+	mux.HandleFunc("GET /api/admin/users", cfg.MiddlewarePermission(auth.PermUsersRead, cfg.GetUsers))
+	mux.HandleFunc("GET /api/admin/users/{id}", cfg.MiddlewarePermission(auth.PermUsersRead, cfg.GetUsers))
+	mux.HandleFunc("DELETE /api/admin/users/{id}", cfg.MiddlewarePermission(auth.PermUsersWrite, cfg.DeleteUser))
+	mux.HandleFunc("PUT /api/admin/users/{id}", cfg.MiddlewarePermission(auth.PermUsersWrite, cfg.UpdateUser))
+	mux.HandleFunc("POST /api/admin/users/{id}/unlock", cfg.MiddlewarePermission(auth.PermUsersWrite, cfg.UnlockUser))
+	mux.HandleFunc("POST /api/glossaries", cfg.MiddlewarePermission(auth.PermGlossariesWrite, cfg.CreateGlossary))
+	mux.HandleFunc("GET /api/glossaries", cfg.MiddlewareIsUser(cfg.GetGlossaries))
+	mux.HandleFunc("GET /api/glossaries/{id}", cfg.MiddlewareIsUser(cfg.GetGlossaries))
+	mux.HandleFunc("PUT /api/glossaries/{id}", cfg.MiddlewarePermission(auth.PermGlossariesWrite, cfg.UpdateGlossary))
+	mux.HandleFunc("DELETE /api/glossaries/{id}", cfg.MiddlewarePermission(auth.PermGlossariesWrite, cfg.DeleteGlossary))
+	mux.HandleFunc("GET /api/admin/users/{id}/usage", cfg.MiddlewarePermission(auth.PermUsageRead, cfg.GetUsage))
+	mux.HandleFunc("PUT /api/admin/users/{id}/quota", cfg.MiddlewarePermission(auth.PermQuotasWrite, cfg.SetQuota))
+	mux.HandleFunc("GET /api/admin/users/{id}/rate-limit", cfg.MiddlewarePermission(auth.PermUsageRead, cfg.GetRateLimit))
+	mux.HandleFunc("PUT /api/admin/users/{id}/rate-limit", cfg.MiddlewarePermission(auth.PermQuotasWrite, cfg.SetRateLimit))
+	mux.HandleFunc("GET /api/admin/users/{id}/api-keys", cfg.MiddlewarePermission(auth.PermUsersRead, cfg.GetAPIKeys))
+	mux.HandleFunc("POST /api/admin/users/{id}/api-keys", cfg.MiddlewarePermission(auth.PermUsersWrite, cfg.CreateAPIKey))
+	mux.HandleFunc("DELETE /api/admin/users/{id}/api-keys/{key_id}", cfg.MiddlewarePermission(auth.PermUsersWrite, cfg.DeleteAPIKey))
+	mux.HandleFunc("GET /api/admin/providers", cfg.MiddlewarePermission(auth.PermProvidersRead, cfg.GetProviderStatus))
+	mux.HandleFunc("GET /api/admin/logs", cfg.MiddlewarePermission(auth.PermLogsRead, cfg.GetLogs))
+	mux.HandleFunc("GET /api/admin/logs/{id}", cfg.MiddlewarePermission(auth.PermLogsRead, cfg.GetLogs))
+	mux.HandleFunc("GET /api/admin/audit", cfg.MiddlewarePermission(auth.PermLogsRead, cfg.GetAuditEvents))
+	mux.HandleFunc("GET /api/admin/cache", cfg.MiddlewarePermission(auth.PermCacheAdmin, cfg.GetCacheStats))
+	mux.HandleFunc("DELETE /api/admin/cache", cfg.MiddlewarePermission(auth.PermCacheAdmin, cfg.PurgeCache))
+	mux.HandleFunc("GET /api/admin/cache/entries", cfg.MiddlewarePermission(auth.PermCacheAdmin, cfg.GetCacheEntries))
+	mux.HandleFunc("DELETE /api/admin/cache/entries/{key}", cfg.MiddlewarePermission(auth.PermCacheAdmin, cfg.InvalidateCacheKey))
+	mux.HandleFunc("GET /api/admin/tm", cfg.MiddlewarePermission(auth.PermTMAdmin, cfg.GetTMEntries))
+	mux.HandleFunc("PUT /api/admin/tm/{id}", cfg.MiddlewarePermission(auth.PermTMAdmin, cfg.UpdateTMEntry))
+	mux.HandleFunc("POST /api/admin/tm/import", cfg.MiddlewarePermission(auth.PermTMAdmin, cfg.ImportTMX))
+	mux.HandleFunc("GET /api/admin/tm/export", cfg.MiddlewarePermission(auth.PermTMAdmin, cfg.ExportTMX))
+	mux.HandleFunc("GET /api/admin/roles", cfg.MiddlewarePermission(auth.PermUsersRead, cfg.GetRoles))
+	mux.HandleFunc("POST /api/admin/orgs", cfg.MiddlewarePermission(auth.PermOrgsAdmin, cfg.CreateOrganization))
+	mux.HandleFunc("GET /api/admin/orgs", cfg.MiddlewarePermission(auth.PermOrgsAdmin, cfg.GetOrganizations))
+	mux.HandleFunc("GET /api/admin/orgs/{id}", cfg.MiddlewarePermission(auth.PermOrgsAdmin, cfg.GetOrganization))
+	mux.HandleFunc("DELETE /api/admin/orgs/{id}", cfg.MiddlewarePermission(auth.PermOrgsAdmin, cfg.DeleteOrganization))
+	mux.HandleFunc("PUT /api/admin/orgs/{id}/quota", cfg.MiddlewarePermission(auth.PermOrgsAdmin, cfg.SetOrganizationQuota))
+	mux.HandleFunc("GET /api/admin/orgs/{id}/usage", cfg.MiddlewarePermission(auth.PermOrgsAdmin, cfg.GetOrganizationUsage))
+	mux.HandleFunc("GET /api/admin/orgs/{id}/members", cfg.MiddlewarePermission(auth.PermOrgsAdmin, cfg.GetOrganizationMembers))
+	mux.HandleFunc("POST /api/admin/orgs/{id}/members", cfg.MiddlewarePermission(auth.PermOrgsAdmin, cfg.AddOrganizationMember))
+	mux.HandleFunc("PUT /api/admin/orgs/{id}/members/{user_id}", cfg.MiddlewarePermission(auth.PermOrgsAdmin, cfg.UpdateOrganizationMember))
+	mux.HandleFunc("DELETE /api/admin/orgs/{id}/members/{user_id}", cfg.MiddlewarePermission(auth.PermOrgsAdmin, cfg.RemoveOrganizationMember))
+	mux.HandleFunc("GET /api/me/org", cfg.MiddlewareIsUser(cfg.GetOrganization))
+	mux.HandleFunc("GET /api/me/org/usage", cfg.MiddlewareIsUser(cfg.GetOrganizationUsage))
+	mux.HandleFunc("GET /api/me/org/members", cfg.MiddlewareIsUser(cfg.GetOrganizationMembers))
+	mux.HandleFunc("POST /api/me/org/members", cfg.MiddlewareIsUser(cfg.AddOrganizationMember))
+	mux.HandleFunc("PUT /api/me/org/members/{user_id}", cfg.MiddlewareIsUser(cfg.UpdateOrganizationMember))
+	mux.HandleFunc("DELETE /api/me/org/members/{user_id}", cfg.MiddlewareIsUser(cfg.RemoveOrganizationMember))
 
 	s := &http.Server{
-		Handler: mux,
-		Addr:    ":" + port,
+		Handler: metrics.Middleware(cfg.MiddlewareRateLimit(mux)),
+		Addr:    conf.ListenAddr,
 	}
 
-	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
+	log.Printf("Serving files from %s on %s\n", filepathRoot, conf.ListenAddr)
 	log.Fatal(s.ListenAndServe())
 
 }
@@ -0,0 +1,40 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (id, created_at, updated_at, name, prefix, key_hash, scopes, expires_at, user_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: GetAPIKey :one
+SELECT *
+FROM api_keys
+WHERE id = $1;
+
+-- name: GetActiveAPIKeyByPrefix :one
+SELECT *
+FROM api_keys
+WHERE prefix = $1 AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: GetAPIKeys :many
+SELECT *
+FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: TouchAPIKey :exec
+-- last use is only written once a minute so busy keys do not write on every request
+UPDATE api_keys
+SET last_used_at = NOW()
+WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute');
+
+-- name: DeleteAPIKey :exec
+DELETE FROM api_keys
+WHERE id = $1;
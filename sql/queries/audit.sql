@@ -0,0 +1,18 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (id, created_at, action, target, ip, details, actor_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+);
+
+-- name: GetAuditEvents :many
+SELECT *
+FROM audit_events
+WHERE (sqlc.narg('action')::text IS NULL OR action = sqlc.narg('action'))
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
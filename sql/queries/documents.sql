@@ -0,0 +1,34 @@
+-- name: CreateDocument :one
+INSERT INTO documents (id, created_at, updated_at, provider,file_name,from_lang,to_lang,status,user_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: GetDocument :one
+SELECT *
+FROM documents
+WHERE id=$1;
+
+-- name: UpdateDocumentStatus :one
+UPDATE documents
+SET status = $2 , error = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteDocument :exec
+DELETE FROM documents
+WHERE id=$1;
+
+-- name: FailStaleDocuments :execrows
+UPDATE documents
+SET status = 'error', error = $1, updated_at = NOW()
+WHERE status IN ('pending', 'translating') AND updated_at < $2;
@@ -0,0 +1,27 @@
+-- name: GetLogs :many
+SELECT logs.id, logs.created_at, logs.is_successful, logs.cached, logs.error,
+    requests.id AS request_id, requests.provider, requests.req_type, requests.from_lang, requests.to_lang,
+    requests.user_id, users.email, requests.organization_id
+FROM logs
+JOIN requests ON logs.request_id = requests.id
+LEFT JOIN users ON requests.user_id = users.id
+WHERE (sqlc.narg('user_id')::uuid IS NULL OR requests.user_id = sqlc.narg('user_id'))
+    AND (sqlc.narg('organization_id')::uuid IS NULL OR requests.organization_id = sqlc.narg('organization_id'))
+    AND (sqlc.narg('provider')::text IS NULL OR requests.provider = sqlc.narg('provider'))
+    AND (sqlc.narg('from_lang')::text IS NULL OR requests.from_lang = sqlc.narg('from_lang'))
+    AND (sqlc.narg('to_lang')::text IS NULL OR requests.to_lang = sqlc.narg('to_lang'))
+    AND (sqlc.narg('created_after')::timestamp IS NULL OR logs.created_at >= sqlc.narg('created_after'))
+    AND (sqlc.narg('created_before')::timestamp IS NULL OR logs.created_at < sqlc.narg('created_before'))
+    AND (sqlc.narg('is_successful')::boolean IS NULL OR logs.is_successful = sqlc.narg('is_successful'))
+    AND (sqlc.narg('cached')::boolean IS NULL OR logs.cached = sqlc.narg('cached'))
+ORDER BY logs.created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: GetLog :one
+SELECT logs.id, logs.created_at, logs.is_successful, logs.cached, logs.error,
+    requests.id AS request_id, requests.provider, requests.req_type, requests.from_lang, requests.to_lang,
+    requests.user_id, users.email, requests.organization_id
+FROM logs
+JOIN requests ON logs.request_id = requests.id
+LEFT JOIN users ON requests.user_id = users.id
+WHERE logs.id=$1;
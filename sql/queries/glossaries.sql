@@ -0,0 +1,60 @@
+-- name: CreateGlossary :one
+INSERT INTO glossaries (id, created_at, updated_at, name,source_lang,target_lang,shared,user_id,organization_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: GetGlossary :one
+SELECT *
+FROM glossaries
+WHERE id=$1;
+
+-- name: GetGlossaries :many
+-- glossaries a user can use, their own, their organization's and shared ones
+SELECT *
+FROM glossaries
+WHERE user_id = sqlc.arg('user_id') OR shared = true
+    OR (organization_id IS NOT NULL AND organization_id = sqlc.narg('organization_id'))
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: UpdateGlossary :one
+UPDATE glossaries
+SET name = $2 , shared = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteGlossary :exec
+DELETE FROM glossaries
+WHERE id=$1;
+
+-- name: GetGlossaryEntries :many
+SELECT *
+FROM glossary_entries
+WHERE glossary_id=$1
+ORDER BY source;
+
+-- name: ReplaceGlossaryEntries :exec
+-- a single statement so the old entries are never gone without the new ones
+WITH deleted AS (
+    DELETE FROM glossary_entries
+    WHERE glossary_id = @glossary_id
+)
+INSERT INTO glossary_entries (id, source, target, glossary_id)
+SELECT gen_random_uuid(), unnest(@sources::text[]), unnest(@targets::text[]), @glossary_id::uuid;
+
+-- name: SetDeepLGlossary :execrows
+-- only replaces the copy the caller saw, so of two servers copying the glossary at once one
+-- wins and the other knows to delete its copy. updated_at is left alone, it is the version
+UPDATE glossaries
+SET deepl_glossary_id = sqlc.arg('deepl_glossary_id'), deepl_glossary_version = sqlc.arg('deepl_glossary_version')
+WHERE id = sqlc.arg('id') AND deepl_glossary_id IS NOT DISTINCT FROM sqlc.narg('previous_id');
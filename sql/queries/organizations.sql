@@ -0,0 +1,63 @@
+-- name: CreateOrganization :one
+INSERT INTO organizations (id, created_at, updated_at, name)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1
+)
+RETURNING *;
+
+-- name: GetOrganization :one
+SELECT *
+FROM organizations
+WHERE id=$1;
+
+-- name: GetOrganizations :many
+SELECT *
+FROM organizations
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2;
+
+-- name: DeleteOrganization :exec
+DELETE FROM organizations
+WHERE id=$1;
+
+-- name: SetOrganizationQuota :one
+UPDATE organizations
+SET monthly_char_limit = $2, monthly_byte_limit = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetMembership :one
+SELECT *
+FROM organization_members
+WHERE user_id=$1;
+
+-- name: AddOrganizationMember :one
+INSERT INTO organization_members (user_id, created_at, updated_at, is_admin, organization_id)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: GetOrganizationMembers :many
+SELECT organization_members.user_id, users.email, organization_members.is_admin, organization_members.created_at
+FROM organization_members
+JOIN users ON users.id = organization_members.user_id
+WHERE organization_members.organization_id = $1
+ORDER BY users.email;
+
+-- name: SetOrganizationMemberAdmin :one
+UPDATE organization_members
+SET is_admin = $3, updated_at = NOW()
+WHERE organization_id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: RemoveOrganizationMember :exec
+DELETE FROM organization_members
+WHERE organization_id = $1 AND user_id = $2;
@@ -0,0 +1,53 @@
+-- name: GetQuota :one
+SELECT *
+FROM quotas
+WHERE user_id=$1;
+
+-- name: SetQuota :one
+INSERT INTO quotas (user_id, created_at, updated_at, monthly_char_limit, monthly_byte_limit)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3
+)
+ON CONFLICT (user_id) DO UPDATE
+SET monthly_char_limit = EXCLUDED.monthly_char_limit, monthly_byte_limit = EXCLUDED.monthly_byte_limit, updated_at = NOW()
+RETURNING *;
+
+-- name: GetUsage :one
+-- only translations that actually reached the provider count, cache hits and failures are free
+SELECT COALESCE(SUM(requests.char_count), 0)::bigint AS chars_used,
+    COALESCE(SUM(requests.byte_count), 0)::bigint AS bytes_used
+FROM requests
+JOIN logs ON logs.request_id = requests.id
+WHERE requests.user_id = sqlc.arg('user_id')::uuid
+    AND requests.created_at >= sqlc.arg('since')
+    AND logs.cached = false
+    AND logs.is_successful = true;
+
+-- name: GetOrganizationUsage :one
+-- usage of everything translated while a member of the organization
+SELECT COALESCE(SUM(requests.char_count), 0)::bigint AS chars_used,
+    COALESCE(SUM(requests.byte_count), 0)::bigint AS bytes_used
+FROM requests
+JOIN logs ON logs.request_id = requests.id
+WHERE requests.organization_id = sqlc.arg('organization_id')::uuid
+    AND requests.created_at >= sqlc.arg('since')
+    AND logs.cached = false
+    AND logs.is_successful = true;
+
+-- name: GetOrganizationUsageByUser :many
+SELECT requests.user_id, users.email,
+    COALESCE(SUM(requests.char_count), 0)::bigint AS chars_used,
+    COALESCE(SUM(requests.byte_count), 0)::bigint AS bytes_used
+FROM requests
+JOIN logs ON logs.request_id = requests.id
+LEFT JOIN users ON users.id = requests.user_id
+WHERE requests.organization_id = sqlc.arg('organization_id')::uuid
+    AND requests.created_at >= sqlc.arg('since')
+    AND logs.cached = false
+    AND logs.is_successful = true
+GROUP BY requests.user_id, users.email
+ORDER BY users.email;
@@ -0,0 +1,30 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (id, created_at, updated_at, token_hash, expires_at, user_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    @token_hash,
+    NOW() + make_interval(secs => @ttl_seconds::float8),
+    @user_id
+)
+RETURNING *;
+
+-- revoking is the check, so of two requests with the same token only one gets the row
+-- name: RevokeActiveRefreshToken :one
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+-- name: IsSessionActive :one
+SELECT EXISTS (
+    SELECT 1
+    FROM refresh_tokens
+    WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+);
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
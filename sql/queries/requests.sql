@@ -1,5 +1,6 @@
 -- name: CreateRequest :one
-INSERT INTO requests (id, created_at, updated_at, provider,req_type,from_lang,to_lang,user_id)
+-- requests are attributed to the organization the user is in when they are made
+INSERT INTO requests (id, created_at, updated_at, provider,req_type,from_lang,to_lang,user_id,char_count,byte_count,organization_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
@@ -8,6 +9,9 @@ VALUES (
     $2,
     $3,
     $4,
-    $5
+    sqlc.arg('user_id')::uuid,
+    $5,
+    $6,
+    (SELECT organization_id FROM organization_members WHERE organization_members.user_id = sqlc.arg('user_id')::uuid)
 )
 RETURNING *;
\ No newline at end of file
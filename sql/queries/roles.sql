@@ -0,0 +1,44 @@
+-- name: GetRoles :many
+SELECT roles.name, roles.description, roles.requests_per_minute,
+    COALESCE(array_agg(role_permissions.permission ORDER BY role_permissions.permission)
+        FILTER (WHERE role_permissions.permission IS NOT NULL), '{}')::text[] AS permissions
+FROM roles
+LEFT JOIN role_permissions ON role_permissions.role = roles.name
+GROUP BY roles.name
+ORDER BY roles.name;
+
+-- name: GetUserRoles :many
+SELECT role
+FROM user_roles
+WHERE user_id = $1
+ORDER BY role;
+
+-- name: GetUsersRoles :many
+SELECT user_id, role
+FROM user_roles
+WHERE user_id = ANY(@user_ids::uuid[])
+ORDER BY role;
+
+-- name: GetUserPermissions :many
+SELECT DISTINCT role_permissions.permission
+FROM user_roles
+JOIN role_permissions ON role_permissions.role = user_roles.role
+WHERE user_roles.user_id = $1
+ORDER BY role_permissions.permission;
+
+-- name: SetUserRoles :exec
+-- removed and added roles are disjoint, so this can run as one statement
+WITH deleted AS (
+    DELETE FROM user_roles
+    WHERE user_id = @user_id AND role <> ALL(@roles::text[])
+)
+INSERT INTO user_roles (user_id, role, created_at)
+SELECT @user_id, unnest(@roles::text[]), NOW()
+ON CONFLICT DO NOTHING;
+
+-- name: GetRoleRateLimit :one
+-- the highest limit of the user's roles, 0 when none of them has one
+SELECT COALESCE(MAX(roles.requests_per_minute), 0)::integer AS requests_per_minute
+FROM user_roles
+JOIN roles ON roles.name = user_roles.role
+WHERE user_roles.user_id = $1;
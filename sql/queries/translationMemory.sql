@@ -0,0 +1,55 @@
+-- name: GetTMExactMatches :many
+-- approved translations of any of the sources
+SELECT *
+FROM tm_entries
+WHERE source_lang = @source_lang AND target_lang = @target_lang AND status = 'approved'
+    AND source_hash = ANY(
+        SELECT md5(s) FROM unnest(@sources::text[]) AS s
+    )
+    AND source = ANY(@sources::text[]);
+
+-- name: SetTMThreshold :exec
+-- the similarity the % operator asks for, until the end of the transaction
+SELECT set_config('pg_trgm.similarity_threshold', @threshold::text, true);
+
+-- name: GetTMFuzzyMatches :many
+-- approved entries at least as similar to the text as SetTMThreshold asks, the closest first
+SELECT sqlc.embed(tm_entries), similarity(source, @text::text)::real AS score
+FROM tm_entries
+WHERE source_lang = @source_lang AND target_lang = @target_lang AND status = 'approved'
+    AND source % @text::text
+ORDER BY score DESC
+LIMIT sqlc.arg('limit');
+
+-- name: RecordTMEntries :exec
+-- machine translations start out pending, entries that already exist are left alone
+INSERT INTO tm_entries (id, created_at, updated_at, source_lang, target_lang, source, target, origin, status)
+SELECT gen_random_uuid(), NOW(), NOW(), @source_lang, @target_lang, unnest(@sources::text[]), unnest(@targets::text[]), @origin, 'pending'
+ON CONFLICT (source_lang, target_lang, source_hash) DO NOTHING;
+
+-- name: ImportTMEntries :execrows
+-- imports replace the translation and status of existing entries
+INSERT INTO tm_entries (id, created_at, updated_at, source_lang, target_lang, source, target, origin, status)
+SELECT gen_random_uuid(), NOW(), NOW(), unnest(@source_langs::text[]), unnest(@target_langs::text[]), unnest(@sources::text[]), unnest(@targets::text[]), 'import', @status
+ON CONFLICT (source_lang, target_lang, source_hash) DO UPDATE
+SET target = excluded.target, origin = excluded.origin, status = excluded.status, updated_at = NOW();
+
+-- name: GetTMEntries :many
+SELECT *
+FROM tm_entries
+WHERE (sqlc.narg('source_lang')::text IS NULL OR source_lang = sqlc.narg('source_lang'))
+    AND (sqlc.narg('target_lang')::text IS NULL OR target_lang = sqlc.narg('target_lang'))
+    AND (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+ORDER BY created_at DESC, id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: GetTMEntry :one
+SELECT *
+FROM tm_entries
+WHERE id = $1;
+
+-- name: UpdateTMEntry :one
+UPDATE tm_entries
+SET target = $2, status = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
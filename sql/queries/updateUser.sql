@@ -1,5 +1,16 @@
 -- name: UpdateUser :one
 UPDATE users
-SET email = $2 , hashed_password = $3 , is_admin = $4, updated_at = NOW()
+SET email = $2 , hashed_password = $3, updated_at = NOW()
 WHERE id = $1
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: UpdatePassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetUserRateLimit :one
+UPDATE users
+SET requests_per_minute = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
@@ -1,11 +1,10 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email,hashed_password,is_admin)
+INSERT INTO users (id, created_at, updated_at, email,hashed_password)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2,
-    $3
+    $2
 )
-RETURNING *;
\ No newline at end of file
+RETURNING *;
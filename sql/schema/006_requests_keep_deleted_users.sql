@@ -0,0 +1,19 @@
+-- +goose Up
+-- requests outlive the user who made them so usage and quotas of their organization stay
+-- accounted for, the user is only unset
+ALTER TABLE requests
+ALTER COLUMN user_id DROP NOT NULL,
+DROP CONSTRAINT requests_user_id_fkey,
+ADD CONSTRAINT requests_user_id_fkey FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE SET NULL;
+
+-- +goose Down
+DELETE FROM logs
+WHERE request_id IN (SELECT id FROM requests WHERE user_id IS NULL);
+
+DELETE FROM requests
+WHERE user_id IS NULL;
+
+ALTER TABLE requests
+DROP CONSTRAINT requests_user_id_fkey,
+ADD CONSTRAINT requests_user_id_fkey FOREIGN KEY(user_id) REFERENCES users(id),
+ALTER COLUMN user_id SET NOT NULL;
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE quotas (
+    user_id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    monthly_char_limit BIGINT,
+    monthly_byte_limit BIGINT,
+
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+ALTER TABLE requests
+ADD COLUMN char_count BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN byte_count BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE requests
+DROP COLUMN char_count,
+DROP COLUMN byte_count;
+
+DROP TABLE quotas;
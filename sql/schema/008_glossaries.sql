@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE glossaries (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    name TEXT NOT NULL,
+    source_lang TEXT NOT NULL,
+    target_lang TEXT NOT NULL,
+    shared BOOLEAN NOT NULL,
+    user_id UUID NOT NULL,
+
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE glossary_entries (
+    id UUID PRIMARY KEY,
+    source TEXT NOT NULL,
+    target TEXT NOT NULL,
+    glossary_id UUID NOT NULL,
+
+    FOREIGN KEY(glossary_id) REFERENCES glossaries(id) ON DELETE CASCADE
+);
+
+CREATE INDEX glossary_entries_glossary_id_idx ON glossary_entries(glossary_id);
+
+-- +goose Down
+DROP TABLE glossary_entries;
+DROP TABLE glossaries;
@@ -0,0 +1,79 @@
+-- +goose Up
+CREATE TABLE roles (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL
+);
+
+CREATE TABLE role_permissions (
+    role TEXT NOT NULL,
+    permission TEXT NOT NULL,
+
+    PRIMARY KEY(role, permission),
+    FOREIGN KEY(role) REFERENCES roles(name) ON DELETE CASCADE
+);
+
+CREATE TABLE user_roles (
+    user_id UUID NOT NULL,
+    role TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(user_id, role),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(role) REFERENCES roles(name) ON DELETE CASCADE
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('viewer', 'read only access to users, usage, logs and providers'),
+    ('translator', 'translate text and documents and manage own glossaries'),
+    ('billing', 'read usage and logs and set quotas'),
+    ('user-admin', 'create, edit and delete users and their API keys'),
+    ('super-admin', 'everything, including assigning roles');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('viewer', 'users.read'),
+    ('viewer', 'usage.read'),
+    ('viewer', 'logs.read'),
+    ('viewer', 'providers.read'),
+    ('translator', 'translate'),
+    ('translator', 'glossaries.write'),
+    ('billing', 'users.read'),
+    ('billing', 'usage.read'),
+    ('billing', 'quotas.write'),
+    ('billing', 'logs.read'),
+    ('user-admin', 'users.read'),
+    ('user-admin', 'users.write'),
+    ('user-admin', 'usage.read'),
+    ('super-admin', 'translate'),
+    ('super-admin', 'glossaries.write'),
+    ('super-admin', 'glossaries.admin'),
+    ('super-admin', 'documents.admin'),
+    ('super-admin', 'users.read'),
+    ('super-admin', 'users.write'),
+    ('super-admin', 'roles.assign'),
+    ('super-admin', 'usage.read'),
+    ('super-admin', 'quotas.write'),
+    ('super-admin', 'logs.read'),
+    ('super-admin', 'providers.read');
+
+-- admins keep full access, everyone else keeps translating
+INSERT INTO user_roles (user_id, role, created_at)
+SELECT id, CASE WHEN is_admin THEN 'super-admin' ELSE 'translator' END, NOW()
+FROM users;
+
+ALTER TABLE users
+DROP COLUMN is_admin;
+
+-- +goose Down
+ALTER TABLE users
+ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE users
+SET is_admin = true
+WHERE id IN (SELECT user_id FROM user_roles WHERE role = 'super-admin');
+
+ALTER TABLE users
+ALTER COLUMN is_admin DROP DEFAULT;
+
+DROP TABLE user_roles;
+DROP TABLE role_permissions;
+DROP TABLE roles;
@@ -0,0 +1,47 @@
+-- +goose Up
+CREATE TABLE organizations (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    name TEXT NOT NULL,
+    monthly_char_limit BIGINT,
+    monthly_byte_limit BIGINT
+);
+
+-- a user belongs to at most one organization
+CREATE TABLE organization_members (
+    user_id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    is_admin BOOLEAN NOT NULL,
+    organization_id UUID NOT NULL,
+
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(organization_id) REFERENCES organizations(id) ON DELETE CASCADE
+);
+
+CREATE INDEX organization_members_organization_id_idx ON organization_members(organization_id);
+
+ALTER TABLE requests
+ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE SET NULL;
+
+CREATE INDEX requests_organization_id_idx ON requests(organization_id);
+
+ALTER TABLE glossaries
+ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE SET NULL;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('super-admin', 'orgs.admin');
+
+-- +goose Down
+DELETE FROM role_permissions
+WHERE permission = 'orgs.admin';
+
+ALTER TABLE glossaries
+DROP COLUMN organization_id;
+
+ALTER TABLE requests
+DROP COLUMN organization_id;
+
+DROP TABLE organization_members;
+DROP TABLE organizations;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE audit_events (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    action TEXT NOT NULL,
+    target TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    details TEXT,
+    actor_id UUID,
+
+    FOREIGN KEY(actor_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX audit_events_created_at_idx ON audit_events(created_at);
+
+-- +goose Down
+DROP TABLE audit_events;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- requests per minute. a user's own limit wins over the highest limit of their roles,
+-- NULL everywhere falls back to RATE_LIMIT
+ALTER TABLE roles
+ADD COLUMN requests_per_minute INTEGER;
+
+ALTER TABLE users
+ADD COLUMN requests_per_minute INTEGER;
+
+UPDATE roles
+SET requests_per_minute = 600
+WHERE name = 'super-admin';
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN requests_per_minute;
+
+ALTER TABLE roles
+DROP COLUMN requests_per_minute;
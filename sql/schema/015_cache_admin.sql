@@ -0,0 +1,7 @@
+-- +goose Up
+INSERT INTO role_permissions (role, permission) VALUES
+    ('super-admin', 'cache.admin');
+
+-- +goose Down
+DELETE FROM role_permissions
+WHERE permission = 'cache.admin';
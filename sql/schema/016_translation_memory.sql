@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- origin is import for TMX imports and the provider's name for machine translations.
+-- status is pending, approved or rejected, only approved entries are used
+CREATE TABLE tm_entries (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    source_lang TEXT NOT NULL,
+    target_lang TEXT NOT NULL,
+    source TEXT NOT NULL,
+    target TEXT NOT NULL,
+    origin TEXT NOT NULL,
+    status TEXT NOT NULL,
+    -- segments can be longer than a btree index entry allows
+    source_hash TEXT GENERATED ALWAYS AS (md5(source)) STORED,
+
+    UNIQUE(source_lang, target_lang, source_hash)
+);
+
+CREATE INDEX tm_entries_status_idx ON tm_entries(status, source_lang, target_lang);
+-- fuzzy matches filter with the % operator, which can use it
+CREATE INDEX tm_entries_source_trgm_idx ON tm_entries USING gin (source gin_trgm_ops);
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('super-admin', 'tm.admin');
+
+-- +goose Down
+DELETE FROM role_permissions
+WHERE permission = 'tm.admin';
+DROP TABLE tm_entries;
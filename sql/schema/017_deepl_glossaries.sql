@@ -0,0 +1,10 @@
+-- +goose Up
+-- the DeepL copy of the glossary and the version it was made from, shared by every server
+ALTER TABLE glossaries
+ADD COLUMN deepl_glossary_id TEXT,
+ADD COLUMN deepl_glossary_version TEXT;
+
+-- +goose Down
+ALTER TABLE glossaries
+DROP COLUMN deepl_glossary_version,
+DROP COLUMN deepl_glossary_id;